@@ -8,3 +8,36 @@
 load("//internal:web_assets.bzl", _web_assets = "web_assets")
 web_assets = _web_assets
 web_asset = _web_assets
+
+load(
+    "//internal:test_runners.bzl",
+    _cypress_test = "cypress_test",
+    _mocha_test = "mocha_test",
+    _node_test = "node_test",
+    _playwright_test = "playwright_test",
+    _vitest_test = "vitest_test",
+)
+mocha_test = _mocha_test
+node_test = _node_test
+vitest_test = _vitest_test
+playwright_test = _playwright_test
+cypress_test = _cypress_test
+
+load("//internal:storybook.bzl", _storybook = "storybook")
+storybook = _storybook
+
+load("//internal:nextjs.bzl", _next_build = "next_build", _next_dev = "next_dev")
+next_build = _next_build
+next_dev = _next_dev
+
+load(
+    "//internal:sfc.bzl",
+    _astro_component = "astro_component",
+    _mdx_library = "mdx_library",
+    _svelte_component = "svelte_component",
+    _vue_component = "vue_component",
+)
+vue_component = _vue_component
+svelte_component = _svelte_component
+astro_component = _astro_component
+mdx_library = _mdx_library
@@ -29,7 +29,9 @@ import (
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
 	"github.com/bazelbuild/buildtools/labels"
 )
 
@@ -63,21 +65,55 @@ type JsConfig struct {
 	ImportAliasPattern *regexp.Regexp
 	Visibility         Visibility
 	CollectBarrels     bool
+	CollectDirectory   bool
 	CollectWebAssets   bool
 	CollectAllAssets   bool
 	CollectedAssets    map[string]bool
+	CollectedStories   map[string]bool
 	CollectAll         bool
 	CollectAllRoot     string
 	CollectAllSources  map[string]bool
+	CollectAllMaxSrcs  int
+	CollectAllTests    string
 	Fix                bool
 	JSRoot             string
 	WebAssetSuffixes   map[string]bool
 	Quiet              bool
 	Verbose            bool
 	DefaultNpmLabel    string
-	JestConfig         string
+	TestRunner         string
+	TestConfigs        map[string]string
+	TestImplicitDeps   map[string][]string
+	JestConfig         *jestConfig
+	UpdateSnapshots    bool
+	FindTestConfigs    bool
+	E2ERunner          string
+	E2EServer          string
+	E2ETags            []string
 	JestTestsPerShard  int
 	JestSize           string
+	JestSecsPerShard   int
+	TestDurations      map[string]float64
+	InferTestOnly      bool
+	TestOnlyPackages   []string
+	FixtureDirs        map[string]bool
+	FixtureRoot        string
+	NextJS             bool
+	NextApp            *nextApp
+	RuleAttrs          map[string]map[string]bzl.Expr
+	TSConfigDiscovery  bool
+	TSConfig           label.Label
+	TSConfigProjects   bool
+	TSConfigProject    *tsConfigJSON
+	MergeCycles        bool
+	ReportCycles       bool
+	NpmPackage         bool
+	NpmLinkPackages    bool
+	CollectedPackages  map[string]string
+	NpmPackageRoot     *npmPackageRoot
+	NpmLinkAll         bool
+	EntryPoints        map[string]bool
+	BinaryMain         bool
 }
 
 func NewJsConfig() *JsConfig {
@@ -98,12 +134,16 @@ func NewJsConfig() *JsConfig {
 			Labels: []string{},
 		},
 		CollectBarrels:    false,
+		CollectDirectory:  false,
 		CollectWebAssets:  false,
 		CollectAllAssets:  false,
 		CollectedAssets:   make(map[string]bool),
+		CollectedStories:  make(map[string]bool),
 		CollectAll:        false,
 		CollectAllRoot:    "",
 		CollectAllSources: make(map[string]bool),
+		CollectAllMaxSrcs: -1,
+		CollectAllTests:   "single",
 		Fix:               false,
 		JSRoot:            "/",
 		WebAssetSuffixes:  make(map[string]bool),
@@ -111,7 +151,37 @@ func NewJsConfig() *JsConfig {
 		Verbose:           false,
 		DefaultNpmLabel:   "//:node_modules/",
 		JestTestsPerShard: -1,
-		JestConfig:        "",
+		JestSecsPerShard:  defaultJestSecondsPerShard,
+		TestDurations:     nil,
+		InferTestOnly:     false,
+		TestOnlyPackages:  defaultTestOnlyPackages,
+		FixtureDirs:       defaultFixtureDirs,
+		FixtureRoot:       "",
+		NextJS:            false,
+		NextApp:           nil,
+		TestRunner:        "",
+		TestConfigs:       make(map[string]string),
+		TestImplicitDeps:  make(map[string][]string),
+		JestConfig:        nil,
+		UpdateSnapshots:   false,
+		FindTestConfigs:   false,
+		E2ERunner:         "",
+		E2EServer:         "",
+		E2ETags:           []string{"e2e", "manual"},
+		RuleAttrs:         make(map[string]map[string]bzl.Expr),
+		TSConfigDiscovery: false,
+		TSConfig:          label.NoLabel,
+		TSConfigProjects:  false,
+		TSConfigProject:   nil,
+		MergeCycles:       false,
+		ReportCycles:      false,
+		NpmPackage:        false,
+		NpmLinkPackages:   false,
+		CollectedPackages: make(map[string]string),
+		NpmPackageRoot:    nil,
+		NpmLinkAll:        false,
+		EntryPoints:       make(map[string]bool),
+		BinaryMain:        false,
 	}
 }
 
@@ -155,17 +225,44 @@ func (parent *JsConfig) NewChild() *JsConfig {
 		child.Visibility.Labels[i] = parent.Visibility.Labels[i]
 	}
 	child.CollectBarrels = parent.CollectBarrels
+	child.CollectDirectory = parent.CollectDirectory
 	child.CollectWebAssets = parent.CollectWebAssets
 	child.CollectAllAssets = parent.CollectAllAssets
 	child.CollectedAssets = parent.CollectedAssets // Reinitialized on change to JSRoot
+	// Reinitialized on change to JSRoot
+	child.CollectedStories = parent.CollectedStories
 
 	child.CollectAll = parent.CollectAll
 	child.CollectAllRoot = parent.CollectAllRoot
 	child.CollectAllSources = parent.CollectAllSources // Copy reference, reinitialized on change to CollectAll
+	child.CollectAllMaxSrcs = parent.CollectAllMaxSrcs
+	child.CollectAllTests = parent.CollectAllTests
 
 	child.JestTestsPerShard = parent.JestTestsPerShard
 	child.JestSize = parent.JestSize
+	child.JestSecsPerShard = parent.JestSecsPerShard
+	child.TestDurations = parent.TestDurations
+	child.InferTestOnly = parent.InferTestOnly
+	child.TestOnlyPackages = parent.TestOnlyPackages
+	child.FixtureDirs = parent.FixtureDirs // Copy reference, reinitialized on change
+	child.FixtureRoot = parent.FixtureRoot
+	child.NextJS = parent.NextJS
+	child.NextApp = parent.NextApp // Copy reference, reinitialized at each app root
+	child.TestRunner = parent.TestRunner
+	child.TestConfigs = make(map[string]string) // copy map
+	for k, v := range parent.TestConfigs {
+		child.TestConfigs[k] = v
+	}
+	child.TestImplicitDeps = make(map[string][]string) // copy map
+	for k, v := range parent.TestImplicitDeps {
+		child.TestImplicitDeps[k] = v
+	}
 	child.JestConfig = parent.JestConfig
+	child.UpdateSnapshots = parent.UpdateSnapshots
+	child.FindTestConfigs = parent.FindTestConfigs
+	child.E2ERunner = parent.E2ERunner
+	child.E2EServer = parent.E2EServer
+	child.E2ETags = parent.E2ETags
 
 	child.JSRoot = parent.JSRoot
 	child.WebAssetSuffixes = make(map[string]bool) // copy map
@@ -176,6 +273,34 @@ func (parent *JsConfig) NewChild() *JsConfig {
 	child.Verbose = parent.Verbose
 	child.DefaultNpmLabel = parent.DefaultNpmLabel
 
+	child.RuleAttrs = make(map[string]map[string]bzl.Expr) // copy map of maps
+	for kind, attrs := range parent.RuleAttrs {
+		child.RuleAttrs[kind] = make(map[string]bzl.Expr)
+		for k, v := range attrs {
+			child.RuleAttrs[kind][k] = v
+		}
+	}
+
+	child.TSConfigDiscovery = parent.TSConfigDiscovery
+	child.TSConfig = parent.TSConfig
+	child.TSConfigProjects = parent.TSConfigProjects
+	child.TSConfigProject = parent.TSConfigProject
+
+	child.MergeCycles = parent.MergeCycles
+	child.ReportCycles = parent.ReportCycles
+
+	child.NpmPackage = parent.NpmPackage
+	child.NpmLinkPackages = parent.NpmLinkPackages
+	child.CollectedPackages = parent.CollectedPackages // Reinitialized on change to JSRoot
+	child.NpmPackageRoot = parent.NpmPackageRoot
+	child.NpmLinkAll = parent.NpmLinkAll
+
+	child.EntryPoints = make(map[string]bool) // copy map
+	for k, v := range parent.EntryPoints {
+		child.EntryPoints[k] = v
+	}
+	child.BinaryMain = parent.BinaryMain
+
 	return child
 }
 
@@ -196,6 +321,8 @@ func newJsConfigsWithRootConfig() JsConfigs {
 	rootConfig := NewJsConfig()
 	rootConfig.JSRoot = "."
 	rootConfig.CollectedAssets = make(map[string]bool)
+	rootConfig.CollectedStories = make(map[string]bool)
+	rootConfig.CollectedPackages = make(map[string]string)
 	return JsConfigs{
 		"": rootConfig,
 	}
@@ -235,13 +362,39 @@ func (*JS) KnownDirectives() []string {
 		"js_collect_all_assets",
 		"js_aggregate_all_assets",
 		"js_collect_all",
+		"js_collect_all_max_srcs",
+		"js_collect_all_tests",
+		"js_granularity",
 		"js_jest_test_per_shard",
+		"js_jest_seconds_per_shard",
+		"js_junit_reports",
+		"js_testonly_inference",
+		"js_testonly_packages",
+		"js_fixture_dirs",
+		"js_nextjs",
 		"js_jest_size",
 		"js_jest_config",
+		"js_test_runner",
+		"js_test_config",
+		"js_test_implicit_deps",
+		"js_update_snapshots",
+		"js_test_config_discovery",
+		"js_e2e_runner",
+		"js_e2e_server",
+		"js_e2e_tags",
 		"js_web_asset",
 		"js_quiet",
 		"js_verbose",
 		"js_default_npm_label",
+		"js_rule_attr",
+		"js_tsconfig",
+		"js_tsconfig_projects",
+		"js_merge_cycles",
+		"js_report_cycles",
+		"js_npm_package",
+		"js_npm_link_packages",
+		"js_entry_point",
+		"js_binary_main",
 	}
 }
 
@@ -272,6 +425,10 @@ func (*JS) Configure(c *config.Config, rel string, f *rule.File) {
 		jsConfigs[rel] = jsConfig
 	}
 
+	// test runners whose config is set by a directive in this directory, which
+	// wins over discovered config files
+	configuredTestRunners := make(map[string]bool)
+
 	// Read directives from existing file
 	if f != nil {
 
@@ -364,6 +521,8 @@ func (*JS) Configure(c *config.Config, rel string, f *rule.File) {
 				} else {
 					jsConfig.JSRoot = jSRoot
 					jsConfig.CollectedAssets = make(map[string]bool)
+					jsConfig.CollectedStories = make(map[string]bool)
+					jsConfig.CollectedPackages = make(map[string]string)
 				}
 
 			case "js_collect_barrels":
@@ -394,12 +553,145 @@ func (*JS) Configure(c *config.Config, rel string, f *rule.File) {
 					jsConfig.CollectAllSources = make(map[string]bool)
 				}
 
+			case "js_collect_all_max_srcs":
+				jsConfig.CollectAllMaxSrcs = readIntDirective(directive)
+
+			case "js_collect_all_tests":
+				switch directive.Value {
+				case "single", "file", "directory":
+					jsConfig.CollectAllTests = directive.Value
+				default:
+					log.Fatalf(Err("failed to read directive %s: %s, only \"single\", \"file\" and \"directory\" are valid", directive.Key, directive.Value))
+				}
+
+			case "js_granularity":
+				switch directive.Value {
+				case "file":
+					jsConfig.CollectBarrels = false
+					jsConfig.CollectDirectory = false
+					jsConfig.CollectAll = false
+				case "barrel":
+					jsConfig.CollectBarrels = true
+					jsConfig.CollectDirectory = false
+					jsConfig.CollectAll = false
+				case "directory":
+					jsConfig.CollectBarrels = false
+					jsConfig.CollectDirectory = true
+					jsConfig.CollectAll = false
+				case "subtree":
+					jsConfig.CollectBarrels = false
+					jsConfig.CollectDirectory = false
+					jsConfig.CollectAllRoot = rel
+					jsConfig.CollectAll = true
+					jsConfig.CollectAllSources = make(map[string]bool)
+				default:
+					log.Fatalf(Err("failed to read directive %s: %s, only \"file\", \"barrel\", \"directory\" and \"subtree\" are valid", directive.Key, directive.Value))
+				}
+
 			case "js_jest_config":
-				jsConfig.JestConfig = labels.ParseRelative(directive.Value, f.Pkg).Format()
+				jsConfig.TestConfigs["jest"] = labels.ParseRelative(directive.Value, f.Pkg).Format()
+				configuredTestRunners["jest"] = true
+
+				// JSON configs are read for their module mappers and setup files
+				jsConfig.JestConfig = nil
+				if configLabel := labels.ParseRelative(directive.Value, f.Pkg); strings.HasSuffix(configLabel.Target, ".json") {
+					jestConfig, err := readJestConfig(c.RepoRoot, configLabel.Package, configLabel.Target)
+					if os.IsNotExist(err) {
+						// generated configs are not analysed
+						if !jsConfig.Quiet {
+							log.Print(Warn("[WARN] %s is not a source file, its module mappers and setup files are not added to tests", directive.Value))
+						}
+					} else if err != nil {
+						log.Fatalf(Err("failed to read directive %s: %v", directive.Key, err))
+					} else {
+						jsConfig.JestConfig = jestConfig
+					}
+				}
+
+			case "js_test_runner":
+				if directive.Value == "auto" {
+					jsConfig.TestRunner = ""
+				} else if _, ok := lookupTestRunner(directive.Value); !ok {
+					log.Fatalf(Err("failed to read directive %s: %s, only \"auto\", \"%s\" are valid", directive.Key, directive.Value, strings.Join(testRunnerNames(), "\", \"")))
+				} else if _, ok := testRunners[directive.Value]; !ok {
+					log.Fatalf(Err("failed to read directive %s: %s is an end-to-end test runner, use js_e2e_runner", directive.Key, directive.Value))
+				} else {
+					jsConfig.TestRunner = directive.Value
+				}
+
+			case "js_test_config":
+				vals := strings.Fields(directive.Value)
+				if len(vals) != 2 {
+					log.Fatalf(Err("failed to read directive %s: %s, expected 2 values", directive.Key, directive.Value))
+				}
+				if _, ok := lookupTestRunner(vals[0]); !ok {
+					log.Fatalf(Err("failed to read directive %s: %s, unknown test runner %s", directive.Key, directive.Value, vals[0]))
+				}
+				jsConfig.TestConfigs[vals[0]] = labels.ParseRelative(vals[1], f.Pkg).Format()
+				configuredTestRunners[vals[0]] = true
+
+			case "js_test_implicit_deps":
+				vals := strings.Fields(directive.Value)
+				if len(vals) == 0 {
+					log.Fatalf(Err("failed to read directive %s: expected a test runner", directive.Key))
+				}
+				if _, ok := lookupTestRunner(vals[0]); !ok {
+					log.Fatalf(Err("failed to read directive %s: %s, unknown test runner %s", directive.Key, directive.Value, vals[0]))
+				}
+				jsConfig.TestImplicitDeps[vals[0]] = vals[1:]
+
+			case "js_update_snapshots":
+				jsConfig.UpdateSnapshots = readBoolDirective(directive)
+
+			case "js_test_config_discovery":
+				jsConfig.FindTestConfigs = readBoolDirective(directive)
+
+			case "js_e2e_runner":
+				if directive.Value == "none" {
+					jsConfig.E2ERunner = ""
+				} else if _, ok := e2eRunners[directive.Value]; !ok {
+					log.Fatalf(Err("failed to read directive %s: %s, only \"none\", \"playwright\" and \"cypress\" are valid", directive.Key, directive.Value))
+				} else {
+					jsConfig.E2ERunner = directive.Value
+				}
+
+			case "js_e2e_server":
+				jsConfig.E2EServer = labels.ParseRelative(directive.Value, f.Pkg).Format()
+
+			case "js_e2e_tags":
+				jsConfig.E2ETags = strings.FieldsFunc(directive.Value, func(r rune) bool { return r == ',' || r == ' ' })
 
 			case "js_jest_test_per_shard":
 				jsConfig.JestTestsPerShard = readIntDirective(directive)
 
+			case "js_jest_seconds_per_shard":
+				jsConfig.JestSecsPerShard = readIntDirective(directive)
+
+			case "js_junit_reports":
+				jsConfig.TestDurations = nil
+				if directive.Value != "" {
+					durations, err := readJUnitReports(path.Join(c.RepoRoot, f.Pkg, directive.Value))
+					if err != nil {
+						log.Fatalf(Err("failed to read directive %s: %v", directive.Key, err))
+					}
+					jsConfig.TestDurations = durations
+				}
+
+			case "js_testonly_inference":
+				jsConfig.InferTestOnly = readBoolDirective(directive)
+
+			case "js_testonly_packages":
+				jsConfig.TestOnlyPackages = strings.Fields(directive.Value)
+
+			case "js_fixture_dirs":
+				jsConfig.FixtureDirs = make(map[string]bool)
+				for _, name := range strings.Fields(directive.Value) {
+					jsConfig.FixtureDirs[name] = true
+				}
+
+			case "js_nextjs":
+				jsConfig.NextJS = readBoolDirective(directive)
+
 			case "js_jest_size":
 				jsConfig.JestSize = directive.Value
 
@@ -418,6 +710,46 @@ func (*JS) Configure(c *config.Config, rel string, f *rule.File) {
 					jsConfig.WebAssetSuffixes[suffix] = status
 				}
 
+			case "js_rule_attr":
+				vals := strings.Fields(directive.Value)
+				if len(vals) < 3 {
+					log.Fatalf(Err("failed to read directive %s: %s, expected 3 values", directive.Key, directive.Value))
+				}
+				kind, attr := vals[0], vals[1]
+				// the value is the rest of the directive, it may hold spaces
+				value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(directive.Value), kind))
+				value = strings.TrimSpace(strings.TrimPrefix(value, attr))
+				if _, ok := jsConfig.RuleAttrs[kind]; !ok {
+					jsConfig.RuleAttrs[kind] = make(map[string]bzl.Expr)
+				}
+				jsConfig.RuleAttrs[kind][attr] = readRuleAttrValue(directive, value, f.Pkg)
+
+			case "js_tsconfig":
+				jsConfig.TSConfigDiscovery = readBoolDirective(directive)
+
+			case "js_tsconfig_projects":
+				jsConfig.TSConfigProjects = readBoolDirective(directive)
+
+			case "js_merge_cycles":
+				jsConfig.MergeCycles = readBoolDirective(directive)
+
+			case "js_report_cycles":
+				jsConfig.ReportCycles = readBoolDirective(directive)
+
+			case "js_npm_package":
+				jsConfig.NpmPackage = readBoolDirective(directive)
+
+			case "js_npm_link_packages":
+				jsConfig.NpmLinkPackages = readBoolDirective(directive)
+
+			case "js_entry_point":
+				for _, entryPoint := range strings.Fields(directive.Value) {
+					jsConfig.EntryPoints[trimExt(path.Join(f.Pkg, entryPoint))] = true
+				}
+
+			case "js_binary_main":
+				jsConfig.BinaryMain = readBoolDirective(directive)
+
 			case "js_quiet":
 				jsConfig.Quiet = readBoolDirective(directive)
 				if jsConfig.Quiet {
@@ -432,6 +764,60 @@ func (*JS) Configure(c *config.Config, rel string, f *rule.File) {
 			}
 		}
 	}
+
+	// The nearest config file of each test runner is used by its tests
+	jsConfig.discoverTestConfigs(c.RepoRoot, rel, f, configuredTestRunners)
+	if !configuredTestRunners["jest"] {
+		jsConfig.discoverJestConfig(c.RepoRoot, rel)
+	}
+
+	// Manual mocks each get their own rule to be added to tests
+	if path.Base(rel) == mocksDirName {
+		jsConfig.CollectBarrels = false
+		jsConfig.CollectDirectory = false
+		jsConfig.CollectAll = false
+	}
+
+	// Fixture directories get a single rule with all their files
+	if jsConfig.FixtureRoot == "" && jsConfig.isFixtureDir(c.RepoRoot, rel) {
+		jsConfig.FixtureRoot = rel
+		jsConfig.CollectBarrels = false
+		jsConfig.CollectDirectory = false
+		jsConfig.CollectAll = false
+	}
+
+	// Next.js apps are built whole, from their routes and public assets
+	jsConfig.configureNextApp(c.RepoRoot, rel)
+
+	// Workspace packages collect the libraries of all their packages
+	jsConfig.configureNpmPackage(c, rel, f)
+
+	// A composite tsconfig.json makes this package the root of a project
+	if jsConfig.TSConfigProjects {
+		jsConfig.TSConfigDiscovery = true
+		if _, ok := tsConfigLabel(c.RepoRoot, rel, tsConfigFileName); ok {
+			tsConfigPath := path.Join(c.RepoRoot, rel, tsConfigFileName)
+			tsConfig, err := readTSConfig(tsConfigPath)
+			if err != nil {
+				log.Fatalf(Err("failed to parse %s: %v", tsConfigPath, err))
+			}
+			if tsConfig.CompilerOptions.Composite {
+				jsConfig.TSConfigProject = tsConfig
+				jsConfig.CollectAllRoot = rel
+				jsConfig.CollectAll = true
+				jsConfig.CollectAllSources = make(map[string]bool)
+			}
+		}
+	}
+
+	// Find the nearest tsconfig.json above this package
+	if jsConfig.TSConfigDiscovery {
+		if tsConfig, ok := tsConfigLabel(c.RepoRoot, rel, tsConfigFileName); ok {
+			jsConfig.TSConfig = tsConfig
+		}
+	} else {
+		jsConfig.TSConfig = label.NoLabel
+	}
 }
 
 var jsTestExtensions = []string{
@@ -522,6 +908,29 @@ func readBoolDirective(directive rule.Directive) bool {
 	}
 }
 
+// readRuleAttrValue parses the value of a js_rule_attr directive as a
+// Starlark expression. Bare words that are not valid expressions are treated
+// as strings, and labels relative to the declaring package are made absolute.
+func readRuleAttrValue(directive rule.Directive, value string, pkg string) bzl.Expr {
+	var expr bzl.Expr = &bzl.StringExpr{Value: value}
+	if f, err := bzl.ParseBuild(directive.Key, []byte("_ = "+value)); err == nil && len(f.Stmt) == 1 {
+		if assign, ok := f.Stmt[0].(*bzl.AssignExpr); ok {
+			ident, isIdent := assign.RHS.(*bzl.Ident)
+			if !isIdent || ident.Name == "True" || ident.Name == "False" || ident.Name == "None" {
+				expr = assign.RHS
+			}
+		}
+	}
+
+	// make relative labels absolute, since the attr is applied in subpackages
+	bzl.Walk(expr, func(e bzl.Expr, _ []bzl.Expr) {
+		if str, ok := e.(*bzl.StringExpr); ok && strings.HasPrefix(str.Value, ":") {
+			str.Value = labels.ParseRelative(str.Value, pkg).Format()
+		}
+	})
+	return expr
+}
+
 func readIntDirective(directive rule.Directive) int {
 	if directive.Value == "" {
 		return -1
@@ -0,0 +1,272 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"context"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// localImportSource returns the source file in srcs that a local import
+// refers to, if any
+func localImportSource(cwd string, imp string, srcs map[string]bool) (string, bool) {
+	baseName := path.Base(imp)
+	if strings.HasPrefix(imp, ".") {
+		// relative imports, including "../" back into this directory
+		dir := cwd
+		if dir == "" {
+			dir = "."
+		}
+		target := path.Join(dir, imp)
+		if target == dir {
+			baseName = "index"
+		} else if path.Dir(target) != dir {
+			return "", false
+		} else {
+			baseName = path.Base(target)
+		}
+	} else if !isLocalImport(cwd, imp) {
+		return "", false
+	}
+	if srcs[baseName] {
+		return baseName, true
+	}
+	for _, ext := range append(tsExtensions, jsExtensions...) {
+		if srcs[baseName+ext] {
+			return baseName + ext, true
+		}
+	}
+	return "", false
+}
+
+// stronglyConnectedComponents returns the strongly connected components of a
+// directed graph with nodes 0..n-1 using Tarjan's algorithm. Nodes within a
+// component, and components, are ordered by their lowest node.
+func stronglyConnectedComponents(n int, edges map[int][]int) [][]int {
+	index := 0
+	indices := make([]int, n)
+	lowLinks := make([]int, n)
+	onStack := make([]bool, n)
+	visited := make([]bool, n)
+	stack := []int{}
+	components := [][]int{}
+
+	var strongConnect func(v int)
+	strongConnect = func(v int) {
+		indices[v] = index
+		lowLinks[v] = index
+		index++
+		visited[v] = true
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range edges[v] {
+			if !visited[w] {
+				strongConnect(w)
+				if lowLinks[w] < lowLinks[v] {
+					lowLinks[v] = lowLinks[w]
+				}
+			} else if onStack[w] && indices[w] < lowLinks[v] {
+				lowLinks[v] = indices[w]
+			}
+		}
+
+		if lowLinks[v] == indices[v] {
+			component := []int{}
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			sort.Ints(component)
+			components = append(components, component)
+		}
+	}
+
+	for v := 0; v < n; v++ {
+		if !visited[v] {
+			strongConnect(v)
+		}
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		return components[i][0] < components[j][0]
+	})
+	return components
+}
+
+// mergeCycles merges rules whose sources import each other into a single
+// rule, so that no cycle is created between the generated targets. The
+// merged rule takes the name of its first member.
+func (lang *JS) mergeCycles(args language.GenerateArgs, jsConfig *JsConfig, rules []*rule.Rule, ruleImports []*imports) ([]*rule.Rule, []*imports) {
+	cwd := args.Rel
+
+	// map each source file to the rule containing it
+	srcRules := make(map[string]int)
+	srcs := make(map[string]bool)
+	for i, r := range rules {
+		for _, src := range r.AttrStrings("srcs") {
+			srcRules[src] = i
+			srcs[src] = true
+		}
+	}
+
+	// build the graph of local imports between rules
+	edges := make(map[int][]int)
+	for i := range rules {
+		for imp := range ruleImports[i].set {
+			if src, ok := localImportSource(cwd, imp, srcs); ok && srcRules[src] != i {
+				edges[i] = append(edges[i], srcRules[src])
+			}
+		}
+		sort.Ints(edges[i])
+	}
+
+	mergedRules := make([]*rule.Rule, 0, len(rules))
+	mergedImports := make([]*imports, 0, len(rules))
+	for _, component := range stronglyConnectedComponents(len(rules), edges) {
+		base := rules[component[0]]
+		if len(component) == 1 {
+			mergedRules = append(mergedRules, base)
+			mergedImports = append(mergedImports, ruleImports[component[0]])
+			continue
+		}
+
+		componentSrcs := []string{}
+		componentImports := []imports{}
+		for _, i := range component {
+			componentSrcs = append(componentSrcs, rules[i].AttrStrings("srcs")...)
+			componentImports = append(componentImports, *ruleImports[i])
+		}
+		sort.Strings(componentSrcs)
+		base.SetAttr("srcs", componentSrcs)
+
+		// cycles merged by a previous run are not reported again
+		if !jsConfig.Quiet && !hasMergedRule(args.File, base, componentSrcs) {
+			log.Print(Warn("[WARN] import cycle in //%s between %s, merged into %s", cwd, strings.Join(componentSrcs, ", "), base.Name()))
+		}
+
+		mergedRules = append(mergedRules, base)
+		mergedImports = append(mergedImports, flattenImports(componentImports))
+	}
+
+	return mergedRules, mergedImports
+}
+
+// hasMergedRule reports whether a build file already has a rule of the kind
+// and name of a merged rule, with all its sources
+func hasMergedRule(f *rule.File, merged *rule.Rule, srcs []string) bool {
+	if f == nil {
+		return false
+	}
+	for _, r := range f.Rules {
+		if r.Kind() != merged.Kind() || r.Name() != merged.Name() {
+			continue
+		}
+		existingSrcs := make(map[string]bool)
+		for _, src := range r.AttrStrings("srcs") {
+			existingSrcs[src] = true
+		}
+		for _, src := range srcs {
+			if !existingSrcs[src] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// dependencyGraph records resolved dependencies between rules of different
+// packages, cycles are reported once all dependencies are resolved
+type dependencyGraph struct {
+	nodes map[string]int
+	names []string
+	edges map[int][]int
+}
+
+func newDependencyGraph() *dependencyGraph {
+	return &dependencyGraph{
+		nodes: make(map[string]int),
+		edges: make(map[int][]int),
+	}
+}
+
+func (g *dependencyGraph) node(name string) int {
+	if n, ok := g.nodes[name]; ok {
+		return n
+	}
+	n := len(g.names)
+	g.nodes[name] = n
+	g.names = append(g.names, name)
+	return n
+}
+
+func (g *dependencyGraph) addEdge(from string, to string) {
+	g.edges[g.node(from)] = append(g.edges[g.node(from)], g.node(to))
+}
+
+// cycles returns the rules of each dependency cycle, sorted
+func (g *dependencyGraph) cycles() [][]string {
+	cycles := [][]string{}
+	for _, component := range stronglyConnectedComponents(len(g.names), g.edges) {
+		if len(component) == 1 {
+			continue
+		}
+		members := make([]string, 0, len(component))
+		for _, n := range component {
+			members = append(members, g.names[n])
+		}
+		sort.Strings(members)
+		cycles = append(cycles, members)
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
+
+// recordDependency records a dependency between two rules in different
+// packages, for the cycles reported after resolution
+func (lang *JS) recordDependency(from label.Label, dep label.Label) {
+	if lang.dependencyGraph == nil {
+		lang.dependencyGraph = newDependencyGraph()
+	}
+	lang.dependencyGraph.addEdge(from.String(), dep.String())
+}
+
+// AfterResolvingDeps reports the dependency cycles between the resolved rules
+func (lang *JS) AfterResolvingDeps(ctx context.Context) {
+	if lang.dependencyGraph == nil {
+		return
+	}
+	for _, cycle := range lang.dependencyGraph.cycles() {
+		log.Print(Warn("[WARN] dependency cycle between %s", strings.Join(cycle, ", ")))
+	}
+	lang.dependencyGraph = nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		n     int
+		edges map[int][]int
+		want  [][]int
+	}{
+		{
+			desc:  "no edges",
+			n:     3,
+			edges: map[int][]int{},
+			want:  [][]int{{0}, {1}, {2}},
+		},
+		{
+			desc:  "chain",
+			n:     3,
+			edges: map[int][]int{0: {1}, 1: {2}},
+			want:  [][]int{{0}, {1}, {2}},
+		},
+		{
+			desc:  "two cycles",
+			n:     5,
+			edges: map[int][]int{0: {3}, 3: {0, 1}, 1: {2}, 2: {4}, 4: {2}},
+			want:  [][]int{{0, 3}, {1}, {2, 4}},
+		},
+		{
+			desc:  "self import",
+			n:     2,
+			edges: map[int][]int{0: {0}, 1: {0}},
+			want:  [][]int{{0}, {1}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := stronglyConnectedComponents(tc.n, tc.edges)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Inequality.\ngot  %#v;\nwant %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDependencyGraphCycles(t *testing.T) {
+	g := newDependencyGraph()
+	g.addEdge("//b:b", "//c:c")
+	g.addEdge("//c:c", "//b:b")
+	g.addEdge("//a:a", "//b:b")
+	g.addEdge("//d:d", "//e:e")
+	g.addEdge("//e:e", "//f:f")
+	g.addEdge("//f:f", "//d:d")
+	want := [][]string{{"//b:b", "//c:c"}, {"//d:d", "//e:e", "//f:f"}}
+	if got := g.cycles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Inequality.\ngot  %#v;\nwant %#v", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+const e2eDirName = "e2e"
+
+var e2eRunners = map[string]*testRunner{
+	"playwright": {
+		kind:         "playwright_test",
+		configAttr:   "config",
+		implicitDeps: []string{"@playwright/test"},
+	},
+	"cypress": {
+		kind:         "cypress_test",
+		configAttr:   "config",
+		implicitDeps: []string{"cypress"},
+	},
+}
+
+var e2eExtensionsPattern = regexp.MustCompile(`\.e2e\.(ts|tsx|js|jsx)$`)
+var e2eDirTestExtensionsPattern = regexp.MustCompile(`\.(e2e|spec|test|cy)\.(ts|tsx|js|jsx)$`)
+
+// hasE2ESources reports whether a package has end-to-end test sources
+func (lang *JS) hasE2ESources(args language.GenerateArgs, jsConfig *JsConfig) bool {
+	for _, baseName := range lang.gatherFiles(args, jsConfig) {
+		if jsConfig.isE2ETest(args.Rel, baseName) {
+			return true
+		}
+	}
+	return false
+}
+
+// isE2ETest reports whether a source file is an end-to-end test, either named
+// like "*.e2e.ts" or a test file in an "e2e" directory, once an end-to-end
+// test runner is selected
+func (jsConfig *JsConfig) isE2ETest(rel string, baseName string) bool {
+	if jsConfig.E2ERunner == "" {
+		return false
+	}
+	if e2eExtensionsPattern.MatchString(baseName) {
+		return true
+	}
+	if !e2eDirTestExtensionsPattern.MatchString(baseName) {
+		return false
+	}
+	for _, dir := range strings.Split(path.Dir(path.Join(rel, baseName)), "/") {
+		if dir == e2eDirName {
+			return true
+		}
+	}
+	return false
+}
+
+func (lang *JS) genE2ETests(args language.GenerateArgs, jsConfig *JsConfig, e2eSources []string) ([]*rule.Rule, []interface{}) {
+	generatedRules := make([]*rule.Rule, 0)
+	generatedImports := make([]interface{}, 0)
+
+	runner := e2eRunners[jsConfig.E2ERunner]
+
+	// the server and the web assets of the app are needed at runtime
+	e2eData := []string{}
+	if jsConfig.E2EServer != "" {
+		server := jsConfig.E2EServer
+		if serverLabel, err := label.Parse(server); err == nil {
+			server = serverLabel.Rel("", args.Rel).String()
+		}
+		e2eData = append(e2eData, server)
+	}
+	if jsConfig.CollectAllAssets {
+		packageLocation := jsConfig.JSRoot
+		if packageLocation == "." {
+			packageLocation = ""
+		}
+		e2eData = append(e2eData, fmt.Sprintf("//%s:all_assets", packageLocation))
+	}
+
+	addE2EAttributes := func(ruleName string, r *rule.Rule) {
+		runner.addTestAttributes(args, jsConfig, jsConfig.E2ERunner, ruleName, r, 0)
+		if len(jsConfig.E2ETags) > 0 {
+			r.SetAttr("tags", jsConfig.E2ETags)
+		}
+		if len(e2eData) > 0 {
+			addExplicitData(r, e2eData)
+		}
+	}
+
+	if !jsConfig.CollectAll {
+		// Add each test as an individual rule
+		for _, baseName := range e2eSources {
+			ruleName := trimExt(baseName)
+			r := rule.NewRule(getKind(args.Config, runner.kind), ruleName)
+			r.SetAttr("srcs", []string{baseName})
+			addE2EAttributes(ruleName, r)
+
+			imports, _, _ := readFileAndParse(path.Join(args.Dir, baseName), "")
+			generatedRules = append(generatedRules, r)
+			generatedImports = append(generatedImports, imports)
+		}
+
+	} else if len(e2eSources) > 0 {
+		// Add all tests as a single rule
+		var allImports []imports
+		for _, baseName := range e2eSources {
+			imps, _, _ := readFileAndParse(path.Join(args.Dir, baseName), path.Dir(baseName))
+			allImports = append(allImports, *imps)
+		}
+
+		ruleName := fmt.Sprintf("%s_e2e", PkgName(args.Rel))
+		r := rule.NewRule(getKind(args.Config, runner.kind), ruleName)
+		r.SetAttr("srcs", e2eSources)
+		addE2EAttributes(ruleName, r)
+
+		generatedRules = append(generatedRules, r)
+		generatedImports = append(generatedImports, flattenImports(allImports))
+	}
+
+	return generatedRules, generatedImports
+}
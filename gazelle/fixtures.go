@@ -0,0 +1,157 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// fixturesRuleName is the name of the rule generated for a fixture directory
+const fixturesRuleName = "fixtures"
+
+// defaultFixtureDirs are the names of fixture directories, unless set by the
+// "js_fixture_dirs" directive
+var defaultFixtureDirs = map[string]bool{
+	"__fixtures__": true,
+}
+
+// isFixtureDir reports whether a directory is named like a fixture directory
+// and holds files, directories with JS or TS sources are regular packages
+func (jsConfig *JsConfig) isFixtureDir(repoRoot string, rel string) bool {
+	if !jsConfig.FixtureDirs[path.Base(rel)] {
+		return false
+	}
+	entries, err := os.ReadDir(path.Join(repoRoot, rel))
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if tsExtensionsPattern.MatchString(entry.Name()) || jsExtensionsPattern.MatchString(entry.Name()) {
+			return false
+		}
+	}
+	return len(entries) > 0
+}
+
+// genFixtures generates a testonly library of all the files of a fixture
+// directory, its sub directories get no rules
+func (lang *JS) genFixtures(args language.GenerateArgs, jsConfig *JsConfig) ([]*rule.Rule, []interface{}) {
+	if args.Rel != jsConfig.FixtureRoot {
+		return nil, nil
+	}
+
+	srcs := listPackageFiles(args)
+	if len(srcs) == 0 {
+		return nil, nil
+	}
+
+	r := rule.NewRule(getKind(args.Config, "js_library"), fixturesRuleName)
+	r.SetAttr("srcs", srcs)
+	r.SetAttr("testonly", true)
+	if len(jsConfig.Visibility.Labels) > 0 {
+		r.SetAttr("visibility", jsConfig.Visibility.Labels)
+	}
+	return []*rule.Rule{r}, []interface{}{&noImports}
+}
+
+// listPackageFiles returns the files of a package, including those of sub
+// directories that are not packages themselves
+func listPackageFiles(args language.GenerateArgs) []string {
+	srcs := []string{}
+	err := filepath.WalkDir(args.Dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(args.Dir, filePath)
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			if rel != "." && hasBuildFile(args.Config.ValidBuildFileNames, filePath) {
+				// files of other packages cannot be listed
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isBuildFileName(args.Config.ValidBuildFileNames, rel) {
+			srcs = append(srcs, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf(Err("Error reading files in %s: %v", args.Rel, err))
+	}
+	sort.Strings(srcs)
+	return srcs
+}
+
+func isBuildFileName(buildFileNames []string, baseName string) bool {
+	for _, name := range buildFileNames {
+		if baseName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasBuildFile(buildFileNames []string, dir string) bool {
+	for _, name := range buildFileNames {
+		if fileInfo, err := os.Stat(path.Join(dir, name)); err == nil && !fileInfo.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// fixtureData returns the fixture libraries of the directories of test sources
+// and of their parent directories
+func (jsConfig *JsConfig) fixtureData(repoRoot string, rel string, srcs []string) []string {
+	labelSet := make(map[string]bool)
+	for _, src := range srcs {
+		dir := path.Dir(path.Join(rel, src))
+		dirs := []string{dir}
+		if dir != "." {
+			dirs = append(dirs, path.Dir(dir))
+		}
+		for _, dir := range dirs {
+			if dir == "." {
+				dir = ""
+			}
+			for name := range jsConfig.FixtureDirs {
+				fixtureDir := path.Join(dir, name)
+				if jsConfig.isFixtureDir(repoRoot, fixtureDir) {
+					labelSet["//"+fixtureDir+":"+fixturesRuleName] = true
+				}
+			}
+		}
+	}
+	labels := make([]string, 0, len(labelSet))
+	for lbl := range labelSet {
+		labels = append(labels, lbl)
+	}
+	sort.Strings(labels)
+	return labels
+}
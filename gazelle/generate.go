@@ -17,15 +17,16 @@
 package js
 
 import (
+	"bufio"
 	"fmt"
 	"log"
-	"math"
 	"os"
 	"path"
 	"sort"
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/language"
 	"github.com/bazelbuild/bazel-gazelle/rule"
 )
@@ -34,25 +35,46 @@ type imports struct {
 	set map[string]bool
 }
 
+// explicitDepsKey is a private attribute of generated rules holding labels
+// known at generation time, they are added to the resolved deps
+const explicitDepsKey = "_js_explicit_deps"
+
+// explicitDataKey is the equivalent of explicitDepsKey for data
+const explicitDataKey = "_js_explicit_data"
+
+// addExplicitData appends labels to the explicit data of a rule
+func addExplicitData(r *rule.Rule, labels []string) {
+	explicitData, _ := r.PrivateAttr(explicitDataKey).([]string)
+	r.SetPrivateAttr(explicitDataKey, append(explicitData, labels...))
+}
+
 var noImports = imports{
 	set: map[string]bool{},
 }
 
 var jsRules = rule.LoadInfo{
 	Name:    "@aspect_rules_js//js:defs.bzl",
-	Symbols: []string{"js_library"},
+	Symbols: []string{"js_library", "js_binary"},
 }
 var tsRules = rule.LoadInfo{
 	Name:    "@aspect_rules_ts//ts:defs.bzl",
-	Symbols: []string{"ts_project"},
+	Symbols: []string{"ts_project", "ts_config"},
 }
 var jestRules = rule.LoadInfo{
 	Name:    "@rules_jest//jest:defs.bzl",
 	Symbols: []string{"jest_test"},
 }
-var webAssetRules = rule.LoadInfo{
+var macroRules = rule.LoadInfo{
 	Name:    "@com_github_benchsci_rules_nodejs_gazelle//:defs.bzl",
-	Symbols: []string{"web_assets"},
+	Symbols: []string{"web_assets", "vitest_test", "mocha_test", "node_test", "playwright_test", "cypress_test", "storybook", "next_build", "next_dev", "vue_component", "svelte_component", "astro_component", "mdx_library"},
+}
+var npmRules = rule.LoadInfo{
+	Name:    "@aspect_rules_js//npm:defs.bzl",
+	Symbols: []string{"npm_package", "npm_link_package"},
+}
+var npmLinkAllRules = rule.LoadInfo{
+	Name:    "@npm//:defs.bzl",
+	Symbols: []string{"npm_link_all_packages"},
 }
 var managedRulesSet map[string]bool
 
@@ -67,7 +89,13 @@ func init() {
 	for _, rule := range jestRules.Symbols {
 		managedRulesSet[rule] = true
 	}
-	for _, rule := range webAssetRules.Symbols {
+	for _, rule := range macroRules.Symbols {
+		managedRulesSet[rule] = true
+	}
+	for _, rule := range npmRules.Symbols {
+		managedRulesSet[rule] = true
+	}
+	for _, rule := range npmLinkAllRules.Symbols {
 		managedRulesSet[rule] = true
 	}
 }
@@ -80,7 +108,9 @@ func (lang *JS) Loads() []rule.LoadInfo {
 		jsRules,
 		tsRules,
 		jestRules,
-		webAssetRules,
+		macroRules,
+		npmRules,
+		npmLinkAllRules,
 	}
 }
 
@@ -128,14 +158,39 @@ func (lang *JS) GenerateRules(args language.GenerateArgs) language.GenerateResul
 		return language.GenerateResult{}
 	}
 
+	if jsConfig.FixtureRoot != "" {
+		// files of fixture directories are data of tests, not sources
+		generatedRules, generatedImports := lang.genFixtures(args, jsConfig)
+		lang.pruneGeneratedRules(args, false, jsConfig, generatedRules)
+		return language.GenerateResult{
+			Gen:     generatedRules,
+			Empty:   []*rule.Rule{},
+			Imports: generatedImports,
+		}
+	}
+
+	if jsConfig.isNextPublicDir(args.Rel) {
+		// files of the public directory of a Next.js app are served as is
+		generatedRules, generatedImports := lang.genNextPublic(args, jsConfig)
+		lang.pruneGeneratedRules(args, false, jsConfig, generatedRules)
+		return language.GenerateResult{
+			Gen:     generatedRules,
+			Empty:   []*rule.Rule{},
+			Imports: generatedImports,
+		}
+	}
+
 	pkgName := PkgName(args.Rel)
 
 	generatedRules := make([]*rule.Rule, 0)
 	generatedImports := make([]interface{}, 0)
 
-	var jestSources,
+	var testSources,
+		e2eSources,
+		storySources,
 		tsSources,
 		jsSources,
+		binSources,
 		webAssetsSet,
 		isBarrel,
 		isJSRoot = lang.collectSources(args, jsConfig)
@@ -151,11 +206,36 @@ func (lang *JS) GenerateRules(args language.GenerateArgs) language.GenerateResul
 		generatedImports = append(generatedImports, &noImports)
 	}
 
-	// add "jest_test" rule(s)
-	generatedTestRules, generatedTestImports := lang.genJestTest(args, jsConfig, jestSources)
+	// add "ts_config" rule(s)
+	generatedTSConfigRules, generatedTSConfigImports := lang.genTSConfigs(args, jsConfig)
+	generatedRules = append(generatedRules, generatedTSConfigRules...)
+	generatedImports = append(generatedImports, generatedTSConfigImports...)
+
+	// add test runner config rule(s)
+	generatedTestConfigRules, generatedTestConfigImports := lang.genTestConfigs(args, jsConfig)
+	generatedRules = append(generatedRules, generatedTestConfigRules...)
+	generatedImports = append(generatedImports, generatedTestConfigImports...)
+
+	// add test rule(s)
+	generatedTestRules, generatedTestImports := lang.genTests(args, jsConfig, testSources)
 	generatedRules = append(generatedRules, generatedTestRules...)
 	generatedImports = append(generatedImports, generatedTestImports...)
 
+	// add end-to-end test rule(s)
+	generatedE2ERules, generatedE2EImports := lang.genE2ETests(args, jsConfig, e2eSources)
+	generatedRules = append(generatedRules, generatedE2ERules...)
+	generatedImports = append(generatedImports, generatedE2EImports...)
+
+	// add "stories" rule
+	generatedStoryRules, generatedStoryImports := lang.genStories(args, jsConfig, pkgName, storySources)
+	generatedRules = append(generatedRules, generatedStoryRules...)
+	generatedImports = append(generatedImports, generatedStoryImports...)
+
+	// add Vue, Svelte, Astro and MDX rule(s)
+	generatedSFCRules, generatedSFCImports := lang.genSFCs(args, jsConfig, pkgName)
+	generatedRules = append(generatedRules, generatedSFCRules...)
+	generatedImports = append(generatedImports, generatedSFCImports...)
+
 	appendTSExt := len(jsSources) > 0
 
 	if len(jsSources) > 0 && jsConfig.CollectAll {
@@ -177,6 +257,11 @@ func (lang *JS) GenerateRules(args language.GenerateArgs) language.GenerateResul
 		generatedImports = append(generatedImports, generatedJSImports...)
 	}
 
+	// add "js_binary" rule(s)
+	generatedBinRules, generatedBinImports := lang.genBinaries(args, jsConfig, binSources, generatedRules)
+	generatedRules = append(generatedRules, generatedBinRules...)
+	generatedImports = append(generatedImports, generatedBinImports...)
+
 	// add "web_assets" rule(s)
 	generatedWARules, generatedWAImports := lang.genWebAssets(args, webAssetsSet, jsConfig)
 	generatedRules = append(generatedRules, generatedWARules...)
@@ -187,8 +272,50 @@ func (lang *JS) GenerateRules(args language.GenerateArgs) language.GenerateResul
 	generatedRules = append(generatedRules, generatedAWARules...)
 	generatedImports = append(generatedImports, generatedAWAImports...)
 
-	existingRules := lang.readExistingRules(args, true)
-	lang.pruneManagedRules(existingRules, generatedRules)
+	// add "storybook" rule of all stories
+	generatedStorybookRules, generatedStorybookImports := lang.genStorybook(args, isJSRoot, jsConfig)
+	generatedRules = append(generatedRules, generatedStorybookRules...)
+	generatedImports = append(generatedImports, generatedStorybookImports...)
+
+	// add "next_build" and "next_dev" rules of a Next.js app
+	generatedNextRules, generatedNextImports := lang.genNextApp(args, jsConfig)
+	generatedRules = append(generatedRules, generatedNextRules...)
+	generatedImports = append(generatedImports, generatedNextImports...)
+
+	// split collected tests depend on the collected library
+	lang.setCollectedTestDeps(args, jsConfig, pkgName, generatedRules)
+
+	// add "tsconfig" attribute to "ts_project" rules
+	lang.setTSConfigAttrs(args, jsConfig, generatedRules)
+	lang.setTSConfigProjectAttrs(args, jsConfig, generatedRules)
+
+	// mark manual mocks as "testonly"
+	lang.setMockAttrs(args, generatedRules)
+
+	// mark test helpers as "testonly"
+	lang.setTestOnlyAttrs(args, jsConfig, generatedRules, generatedImports)
+
+	// record libraries for the npm_package rule of their workspace package
+	lang.recordNpmPackageSrcs(args, jsConfig, generatedRules)
+
+	// add "npm_package" rule for package.json
+	generatedNpmRules, generatedNpmImports := lang.genNpmPackage(args, jsConfig)
+	generatedRules = append(generatedRules, generatedNpmRules...)
+	generatedImports = append(generatedImports, generatedNpmImports...)
+
+	// add "npm_link_package" or "npm_link_all_packages" rules for workspace
+	// packages
+	generatedLinkRules, generatedLinkImports := lang.genNpmLinks(args, isJSRoot, jsConfig)
+	generatedRules = append(generatedRules, generatedLinkRules...)
+	generatedImports = append(generatedImports, generatedLinkImports...)
+
+	// add attributes from "js_rule_attr" directives
+	lang.applyRuleAttrs(args, jsConfig, generatedRules)
+
+	// record route libraries for the next rules of the app
+	lang.recordNextRoutes(args, jsConfig, generatedRules)
+
+	lang.pruneGeneratedRules(args, isJSRoot, jsConfig, generatedRules)
 
 	return language.GenerateResult{
 		Gen:     generatedRules,
@@ -197,12 +324,15 @@ func (lang *JS) GenerateRules(args language.GenerateArgs) language.GenerateResul
 	}
 }
 
-func (lang *JS) collectSources(args language.GenerateArgs, jsConfig *JsConfig) ([]string, []string, []string, map[string]bool, bool, bool) {
+func (lang *JS) collectSources(args language.GenerateArgs, jsConfig *JsConfig) ([]string, []string, []string, []string, []string, []string, map[string]bool, bool, bool) {
 
 	managedFiles := make(map[string]bool)
-	jestSources := []string{}
+	testSources := []string{}
+	e2eSources := []string{}
+	storySources := []string{}
 	tsSources := []string{}
 	jsSources := []string{}
+	binSources := []string{}
 	webAssetsSet := make(map[string]bool)
 
 	isBarrel := false
@@ -210,6 +340,8 @@ func (lang *JS) collectSources(args language.GenerateArgs, jsConfig *JsConfig) (
 	absJSRoot := path.Join(args.Config.RepoRoot, jsConfig.JSRoot)
 	isJSRoot := absJSRoot == args.Dir
 
+	entryPoints := lang.collectEntryPoints(args, jsConfig)
+
 	for _, baseName := range lang.gatherFiles(args, jsConfig) {
 
 		alwaysIgnoredFiles := map[string]bool{
@@ -221,13 +353,37 @@ func (lang *JS) collectSources(args language.GenerateArgs, jsConfig *JsConfig) (
 		if _, ignored := alwaysIgnoredFiles[baseName]; ignored {
 			continue
 		}
+		if jsConfig.TSConfigDiscovery && isTSConfigFile(baseName) {
+			// tsconfig files get their own "ts_config" rules
+			continue
+		}
+		if jsConfig.isTestConfigFile(baseName) {
+			// test runner config files get their own rules
+			continue
+		}
+		if jsConfig.isNextAppFile(args.Rel, baseName) {
+			// Next.js config files are data of the next rules
+			continue
+		}
 
 		managedFiles[baseName] = true
 
+		// E2E TEST
+		if jsConfig.isE2ETest(args.Rel, baseName) {
+			e2eSources = append(e2eSources, baseName)
+			continue
+		}
+
+		// STORIES
+		if isStoriesFile(baseName) {
+			storySources = append(storySources, baseName)
+			continue
+		}
+
 		// TS & JS TEST
 		match := append(jsTestExtensionsPattern.FindStringSubmatch(baseName), tsTestExtensionsPattern.FindStringSubmatch(baseName)...)
 		if len(match) > 0 {
-			jestSources = append(jestSources, baseName)
+			testSources = append(testSources, baseName)
 			continue
 		}
 
@@ -236,16 +392,27 @@ func (lang *JS) collectSources(args language.GenerateArgs, jsConfig *JsConfig) (
 			isBarrel = true
 		}
 
+		// sources outside of the tsconfig project are not compiled
+		if jsConfig.TSConfigProject != nil && !jsConfig.TSConfigProject.includes(baseName) {
+			continue
+		}
+
 		// TS
 		match = tsExtensionsPattern.FindStringSubmatch(baseName)
 		if len(match) > 0 {
 			tsSources = append(tsSources, baseName)
+			if entryPoints[trimExt(path.Join(args.Rel, baseName))] || isExecutableFile(path.Join(args.Dir, baseName)) {
+				binSources = append(binSources, baseName)
+			}
 			continue
 		}
 		// JS
 		match = jsExtensionsPattern.FindStringSubmatch(baseName)
 		if len(match) > 0 {
 			jsSources = append(jsSources, baseName)
+			if entryPoints[trimExt(path.Join(args.Rel, baseName))] || isExecutableFile(path.Join(args.Dir, baseName)) {
+				binSources = append(binSources, baseName)
+			}
 			continue
 		}
 
@@ -256,9 +423,12 @@ func (lang *JS) collectSources(args language.GenerateArgs, jsConfig *JsConfig) (
 
 	}
 
-	return jestSources,
+	return testSources,
+		e2eSources,
+		storySources,
 		tsSources,
 		jsSources,
+		binSources,
 		webAssetsSet,
 		isBarrel,
 		isJSRoot
@@ -273,6 +443,39 @@ func (lang *JS) isWebAsset(jsConfig *JsConfig, baseName string) bool {
 	return false
 }
 
+// collectEntryPoints returns the entry points declared for this directory by
+// package.json and "js_entry_point" directives, without extensions
+func (lang *JS) collectEntryPoints(args language.GenerateArgs, jsConfig *JsConfig) map[string]bool {
+	entryPoints := make(map[string]bool)
+	for entryPoint, ok := range jsConfig.EntryPoints {
+		entryPoints[entryPoint] = ok
+	}
+	for _, baseName := range args.RegularFiles {
+		if baseName != "package.json" {
+			continue
+		}
+		packageEntryPoints, err := readPackageEntryPoints(path.Join(args.Dir, baseName), jsConfig.BinaryMain)
+		if err != nil {
+			log.Fatalf(Err("failed to parse %s: %v", path.Join(args.Rel, baseName), err))
+		}
+		for _, entryPoint := range packageEntryPoints {
+			entryPoints[trimExt(path.Join(args.Rel, entryPoint))] = true
+		}
+	}
+	return entryPoints
+}
+
+// isExecutableFile reports whether a source file starts with a node shebang
+func isExecutableFile(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	firstLine, _ := bufio.NewReader(file).ReadString('\n')
+	return strings.HasPrefix(firstLine, "#!") && strings.Contains(firstLine, "node")
+}
+
 func (lang *JS) gatherFiles(args language.GenerateArgs, jsConfig *JsConfig) []string {
 	allFiles := args.RegularFiles
 	if jsConfig.CollectAll {
@@ -283,7 +486,7 @@ func (lang *JS) gatherFiles(args language.GenerateArgs, jsConfig *JsConfig) []st
 	return allFiles
 }
 
-func readFileAndParse(filePath string, rel string) (*imports, int) {
+func readFileAndParse(filePath string, rel string) (*imports, int, map[string]string) {
 
 	fileImports := imports{
 		set: make(map[string]bool),
@@ -299,7 +502,7 @@ func readFileAndParse(filePath string, rel string) (*imports, int) {
 	if err != nil {
 		log.Fatalf(Err("Error reading %s: %v", filePath, err))
 	}
-	jsImports, testCount, err := ParseJS(data)
+	jsImports, testCount, pragmas, err := ParseJS(data)
 	if err != nil {
 		log.Fatalf(Err("Error parsing %s: %v", filePath, err))
 	}
@@ -310,7 +513,7 @@ func readFileAndParse(filePath string, rel string) (*imports, int) {
 		fileImports.set[imp] = true
 	}
 
-	return &fileImports, testCount
+	return &fileImports, testCount, pragmas
 }
 
 func (lang *JS) genPkgRule(args language.GenerateArgs, jsConfig *JsConfig) *rule.Rule {
@@ -327,61 +530,222 @@ func (lang *JS) genPkgRule(args language.GenerateArgs, jsConfig *JsConfig) *rule
 	return nil
 }
 
-func (lang *JS) genJestTest(args language.GenerateArgs, jsConfig *JsConfig, jestSources []string) ([]*rule.Rule, []interface{}) {
+func (lang *JS) genTests(args language.GenerateArgs, jsConfig *JsConfig, testSources []string) ([]*rule.Rule, []interface{}) {
 	generatedRules := make([]*rule.Rule, 0)
 	generatedImports := make([]interface{}, 0)
 
+	runnerName := jsConfig.testRunnerName()
+	runner := testRunners[runnerName]
+
+	// modules loaded by the jest config are imported by every test, except
+	// those provided by the test rule
+	setupImports := []string{}
+	if runnerName == "jest" && jsConfig.JestConfig != nil {
+		for _, imp := range jsConfig.JestConfig.setupImports(args.Rel) {
+			if !isExcludedDep(runner.excludedDeps, imp) {
+				setupImports = append(setupImports, imp)
+			}
+		}
+	}
+
 	if !jsConfig.CollectAll {
 		// Add each test as an individual rule
-		for _, baseName := range jestSources {
+		for _, baseName := range testSources {
 			match := append(jsTestExtensionsPattern.FindStringSubmatch(baseName), tsTestExtensionsPattern.FindStringSubmatch(baseName)...)
 			filePath := path.Join(args.Dir, baseName)
 			extension := match[0]
 
 			ruleName := strings.TrimSuffix(baseName, extension) + ".test"
 			r := rule.NewRule(
-				getKind(args.Config, "jest_test"),
+				getKind(args.Config, runner.kind),
 				ruleName,
 			)
 			r.SetAttr("srcs", []string{baseName})
 
-			imports, jestTestCount := readFileAndParse(filePath, "")
+			imports, testCount, pragmas := readFileAndParse(filePath, "")
+			for _, imp := range setupImports {
+				imports.set[imp] = true
+			}
 
-			lang.addJestAttributes(args, jsConfig, ruleName, r, jestTestCount)
+			runner.addTestAttributes(args, jsConfig, runnerName, ruleName, r, testCount)
+			applyTestPragmas(jsConfig, runnerName, r, imports, []testFilePragmas{{pragmas: pragmas}})
 
 			generatedRules = append(generatedRules, r)
 			generatedImports = append(generatedImports, imports)
 		}
 
-	} else if len(jestSources) > 0 {
-		// Add all tests as a single rule
-		jestTestCount := 0
-		var allImports []imports
-		for _, baseName := range jestSources {
-			filePath := path.Join(args.Dir, baseName)
-			relativePart := path.Dir(baseName)
-			imps, tCount := readFileAndParse(filePath, relativePart)
-			jestTestCount += tCount
-			allImports = append(allImports, *imps)
-		}
-		imports := flattenImports(allImports)
-
-		pkgName := PkgName(args.Rel)
-		ruleName := fmt.Sprintf("%s_test", pkgName)
-		r := rule.NewRule(
-			getKind(args.Config, "jest_test"),
-			ruleName,
-		)
-
-		r.SetAttr("srcs", jestSources)
-		lang.addJestAttributes(args, jsConfig, ruleName, r, jestTestCount)
+	} else {
+		// Add all tests as a single rule, or a rule per file or directory
+		for _, group := range jsConfig.groupCollectedTests(PkgName(args.Rel), testSources) {
+			r, imports := lang.makeCollectedTestRule(args, jsConfig, runnerName, group.name, group.srcs, setupImports)
+			generatedRules = append(generatedRules, r)
+			generatedImports = append(generatedImports, imports)
+		}
+	}
+
+	return generatedRules, generatedImports
+}
+
+// makeCollectedTestRule generates a test rule for test sources collected from
+// the subtree of the package
+func (lang *JS) makeCollectedTestRule(args language.GenerateArgs, jsConfig *JsConfig, runnerName string, ruleName string, testSources []string, setupImports []string) (*rule.Rule, *imports) {
+	runner := testRunners[runnerName]
+
+	testCount := 0
+	var allImports []imports
+	var allPragmas []testFilePragmas
+	for _, baseName := range testSources {
+		filePath := path.Join(args.Dir, baseName)
+		relativePart := path.Dir(baseName)
+		imps, tCount, filePragmas := readFileAndParse(filePath, relativePart)
+		testCount += tCount
+		allImports = append(allImports, *imps)
+		allPragmas = append(allPragmas, testFilePragmas{rel: relativePart, pragmas: filePragmas})
+	}
+	imports := flattenImports(allImports)
+	for _, imp := range setupImports {
+		imports.set[imp] = true
+	}
+
+	r := rule.NewRule(
+		getKind(args.Config, runner.kind),
+		ruleName,
+	)
+
+	r.SetAttr("srcs", testSources)
+	runner.addTestAttributes(args, jsConfig, runnerName, ruleName, r, testCount)
+	applyTestPragmas(jsConfig, runnerName, r, imports, allPragmas)
+	return r, imports
+}
+
+type testGroup struct {
+	name string
+	srcs []string
+}
+
+// groupCollectedTests groups the test sources collected from the subtree of a
+// package by the "js_collect_all_tests" mode, test rules are named like the
+// pieces of the collected library
+func (jsConfig *JsConfig) groupCollectedTests(pkgName string, testSources []string) []testGroup {
+	if len(testSources) == 0 {
+		return nil
+	}
+	// collected sources come in no particular order
+	testSources = append([]string{}, testSources...)
+	sort.Strings(testSources)
+	switch jsConfig.CollectAllTests {
+	case "file":
+		groups := make([]testGroup, 0, len(testSources))
+		for _, src := range testSources {
+			match := append(jsTestExtensionsPattern.FindStringSubmatch(src), tsTestExtensionsPattern.FindStringSubmatch(src)...)
+			name := strings.ReplaceAll(strings.TrimSuffix(src, match[0]), "/", "_") + ".test"
+			groups = append(groups, testGroup{name: name, srcs: []string{src}})
+		}
+		return groups
+	case "directory":
+		dirSrcs := make(map[string][]string)
+		dirs := make([]string, 0)
+		for _, src := range testSources {
+			dir := path.Dir(src)
+			if _, ok := dirSrcs[dir]; !ok {
+				dirs = append(dirs, dir)
+			}
+			dirSrcs[dir] = append(dirSrcs[dir], src)
+		}
+		sort.Strings(dirs)
+		groups := make([]testGroup, 0, len(dirs))
+		for _, dir := range dirs {
+			name := pkgName
+			if dir != "." {
+				name = pkgName + "_" + strings.ReplaceAll(dir, "/", "_")
+			}
+			groups = append(groups, testGroup{name: name + "_test", srcs: dirSrcs[dir]})
+		}
+		return groups
+	}
+	return []testGroup{{name: fmt.Sprintf("%s_test", pkgName), srcs: testSources}}
+}
+
+// setCollectedTestDeps makes the test rules split from the tests collected
+// from the subtree of a package depend on the collected library
+func (lang *JS) setCollectedTestDeps(args language.GenerateArgs, jsConfig *JsConfig, pkgName string, generatedRules []*rule.Rule) {
+	if !jsConfig.CollectAll || jsConfig.CollectAllTests == "single" {
+		return
+	}
+	hasLibrary := false
+	for _, r := range generatedRules {
+		if r.Name() == pkgName && (r.Kind() == getKind(args.Config, "ts_project") || r.Kind() == getKind(args.Config, "js_library")) {
+			hasLibrary = true
+		}
+	}
+	if !hasLibrary {
+		return
+	}
+	runner := testRunners[jsConfig.testRunnerName()]
+	for _, r := range generatedRules {
+		if r.Kind() == getKind(args.Config, runner.kind) {
+			explicitDeps, _ := r.PrivateAttr(explicitDepsKey).([]string)
+			r.SetPrivateAttr(explicitDepsKey, append(explicitDeps, ":"+pkgName))
+		}
+	}
+}
+
+func (lang *JS) genBinaries(args language.GenerateArgs, jsConfig *JsConfig, binSources []string, libraryRules []*rule.Rule) ([]*rule.Rule, []interface{}) {
+	generatedRules := make([]*rule.Rule, 0)
+	generatedImports := make([]interface{}, 0)
+
+	// map each source file to the library rule containing it
+	srcRules := make(map[string]*rule.Rule)
+	for _, r := range libraryRules {
+		if r.Kind() != getKind(args.Config, "ts_project") && r.Kind() != getKind(args.Config, "js_library") {
+			continue
+		}
+		for _, src := range r.AttrStrings("srcs") {
+			srcRules[src] = r
+		}
+	}
+
+	for _, baseName := range binSources {
+		library, ok := srcRules[baseName]
+		if !ok {
+			continue
+		}
+
+		entryPoint := compiledEntryPoint(baseName)
+		r := rule.NewRule(getKind(args.Config, "js_binary"), binaryRuleName(entryPoint))
+		r.SetAttr("entry_point", entryPoint)
+		r.SetPrivateAttr(explicitDataKey, []string{":" + library.Name()})
+		if len(jsConfig.Visibility.Labels) > 0 {
+			r.SetAttr("visibility", jsConfig.Visibility.Labels)
+		}
+
 		generatedRules = append(generatedRules, r)
-		generatedImports = append(generatedImports, imports)
+		generatedImports = append(generatedImports, &noImports)
 	}
 
 	return generatedRules, generatedImports
 }
 
+// compiledEntryPoint returns the file run for an entry point, ts sources are
+// run from their compiled output
+func compiledEntryPoint(baseName string) string {
+	ext := path.Ext(baseName)
+	switch ext {
+	case ".ts", ".tsx":
+		return strings.TrimSuffix(baseName, ext) + ".js"
+	case ".mts":
+		return strings.TrimSuffix(baseName, ext) + ".mjs"
+	case ".cts":
+		return strings.TrimSuffix(baseName, ext) + ".cjs"
+	}
+	return baseName
+}
+
+// binaryRuleName returns the name of the binary generated for an entry point
+func binaryRuleName(entryPoint string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(entryPoint, path.Ext(entryPoint)), "/", "_") + "_bin"
+}
+
 type testRuleArgs struct {
 	ruleType  string
 	extension string
@@ -390,33 +754,15 @@ type testRuleArgs struct {
 }
 
 func (lang *JS) makeFolderTestRule(args language.GenerateArgs, jsConfig *JsConfig, testRuleArgs testRuleArgs) (*imports, *rule.Rule) {
-	imps, jestTestCount := readFileAndParse(testRuleArgs.filePath, "")
+	imps, testCount, _ := readFileAndParse(testRuleArgs.filePath, "")
 	ruleName := strings.TrimSuffix(testRuleArgs.baseName, testRuleArgs.extension) + ".test"
 	r := rule.NewRule(testRuleArgs.ruleType, ruleName)
 	r.SetAttr("srcs", []string{testRuleArgs.baseName})
-	lang.addJestAttributes(args, jsConfig, ruleName, r, jestTestCount)
+	runnerName := jsConfig.testRunnerName()
+	testRunners[runnerName].addTestAttributes(args, jsConfig, runnerName, ruleName, r, testCount)
 	return imps, r
 }
 
-func (lang *JS) addJestAttributes(args language.GenerateArgs, jsConfig *JsConfig, baseName string, r *rule.Rule, jestTestCount int) {
-	if jsConfig.JestConfig == "" && !jsConfig.Quiet {
-		log.Print(Warn("[%s/%s] no config for jest_test, use gazelle:js_jest_config directive", args.Rel, baseName))
-	}
-	r.SetAttr("config", jsConfig.JestConfig)
-	if jsConfig.JestTestsPerShard > 0 {
-		shardCount := int(math.Ceil(float64(jestTestCount) / float64(jsConfig.JestTestsPerShard)))
-		if shardCount > 1 {
-			r.SetAttr("shard_count", shardCount)
-		}
-	}
-	if jsConfig.JestSize != "" {
-		r.SetAttr("size", jsConfig.JestSize)
-	}
-	if len(jsConfig.Visibility.Labels) > 0 {
-		r.SetAttr("visibility", jsConfig.Visibility.Labels)
-	}
-}
-
 func (lang *JS) genRules(args language.GenerateArgs, jsConfig *JsConfig, isBarrel bool, isJSRoot bool, pkgName string, sources []string, appendTSExt bool, kind string) ([]*rule.Rule, []interface{}) {
 
 	// Parse files to get imports
@@ -427,11 +773,12 @@ func (lang *JS) genRules(args language.GenerateArgs, jsConfig *JsConfig, isBarre
 		if jsConfig.CollectAll {
 			relativePart = path.Dir(baseName)
 		}
-		imps, _ := readFileAndParse(filePath, relativePart)
+		imps, _, _ := readFileAndParse(filePath, relativePart)
 		imports = append(imports, *imps)
 	}
 
 	collectBarrel := jsConfig.CollectBarrels && isBarrel && !isJSRoot
+	collectDirectory := jsConfig.CollectDirectory && args.Rel != ""
 
 	generatedRules := make([]*rule.Rule, 0)
 	generatedImports := make([]interface{}, 0)
@@ -454,15 +801,44 @@ func (lang *JS) genRules(args language.GenerateArgs, jsConfig *JsConfig, isBarre
 				}
 			}
 
-			folderImports, folderRule := lang.makeFolderRule(moduleRuleArgs{
+			folderImports, folderRules := lang.makeFolderRules(moduleRuleArgs{
 				pkgName:  name,
 				cwd:      args.Rel,
 				ruleType: getKind(args.Config, kind),
 				srcs:     sources,
 				imports:  imports,
 			}, jsConfig)
-			generatedRules = append(generatedRules, folderRule)
-			generatedImports = append(generatedImports, folderImports)
+			if folderRules[0].Name() != name {
+				// the root directory has no srcs, keep a rule named after
+				// the package for its dependents, depending on all pieces
+				pieceLabels := make([]string, 0, len(folderRules))
+				for _, r := range folderRules {
+					pieceLabels = append(pieceLabels, ":"+r.Name())
+				}
+				pkgRule := rule.NewRule(getKind(args.Config, "js_library"), name)
+				pkgRule.SetPrivateAttr(explicitDepsKey, pieceLabels)
+				if len(jsConfig.Visibility.Labels) > 0 {
+					pkgRule.SetAttr("visibility", jsConfig.Visibility.Labels)
+				}
+				generatedRules = append(generatedRules, pkgRule)
+				generatedImports = append(generatedImports, &noImports)
+			}
+			for i := range folderRules {
+				generatedRules = append(generatedRules, folderRules[i])
+				generatedImports = append(generatedImports, folderImports[i])
+			}
+
+		} else if collectDirectory {
+			// add as a directory
+			directoryImports, directoryRule := lang.makeFolderRule(moduleRuleArgs{
+				pkgName:  name,
+				cwd:      args.Rel,
+				ruleType: getKind(args.Config, kind),
+				srcs:     sources,
+				imports:  imports,
+			}, jsConfig)
+			generatedRules = append(generatedRules, directoryRule)
+			generatedImports = append(generatedImports, directoryImports)
 
 		} else if collectBarrel {
 			// add as a module (barrel file)
@@ -476,6 +852,9 @@ func (lang *JS) genRules(args language.GenerateArgs, jsConfig *JsConfig, isBarre
 			if !jsConfig.Quiet && len(moduleRules) > 1 {
 				log.Print(Warn("[WARN] disjoint barrel %s", args.Rel))
 			}
+			if jsConfig.MergeCycles {
+				moduleRules, moduleImports = lang.mergeCycles(args, jsConfig, moduleRules, moduleImports)
+			}
 			for i := range moduleRules {
 				generatedRules = append(generatedRules, moduleRules[i])
 				generatedImports = append(generatedImports, moduleImports[i])
@@ -487,9 +866,13 @@ func (lang *JS) genRules(args language.GenerateArgs, jsConfig *JsConfig, isBarre
 				srcs:     sources,
 				trimExt:  true,
 			}, jsConfig)
+			singletonImports := importRefs(imports)
+			if jsConfig.MergeCycles {
+				singletonRules, singletonImports = lang.mergeCycles(args, jsConfig, singletonRules, singletonImports)
+			}
 			for i := range singletonRules {
 				generatedRules = append(generatedRules, singletonRules[i])
-				generatedImports = append(generatedImports, &imports[i])
+				generatedImports = append(generatedImports, singletonImports[i])
 			}
 		}
 	}
@@ -550,39 +933,46 @@ func (lang *JS) makeModuleRules(args moduleRuleArgs, jsConfig *JsConfig) ([]*imp
 		}
 	}
 
-	// recurse through each import for src file
-	// which, if it's local, transitively belongs in the moduleSet
-	recAddTransitiveSet := func(src string) {
-		// for each import that the source file has
-		for imp, _ := range moduleSet[src].set {
-			// if that import is local to this directory
-			if isLocalImport(args.cwd, imp) {
-				// check the remainderSet to see if the src file corresponding to the import
-				// exists and also hasn't been included in the module yet
-				basename := path.Base(imp)
-
-				for _, ext := range append(tsExtensions, jsExtensions...) {
-					filename := basename + ext
-					if _, ok := remainderSet[filename]; ok {
-						// copy the src file out of the remainderSet and into the moduleSet
-						moduleSet[filename] = remainderSet[filename]
-						delete(remainderSet, filename)
-						break
-					}
-				}
+	remainderFiles := make(map[string]bool)
+	for src := range remainderSet {
+		remainderFiles[src] = true
+	}
+
+	// starting with index, every src file reached through local imports
+	// transitively belongs in the moduleSet
+	queue := []string{indexKey}
+	for len(queue) > 0 {
+		src := queue[0]
+		queue = queue[1:]
+
+		// for each import that the source file has, in a deterministic order
+		srcImports := make([]string, 0, len(moduleSet[src].set))
+		for imp := range moduleSet[src].set {
+			srcImports = append(srcImports, imp)
+		}
+		sort.Strings(srcImports)
+
+		for _, imp := range srcImports {
+			// if that import is a src file in this directory which hasn't been
+			// included in the module yet, move it out of the remainderSet
+			if filename, ok := localImportSource(args.cwd, imp, remainderFiles); ok {
+				moduleSet[filename] = remainderSet[filename]
+				delete(remainderSet, filename)
+				delete(remainderFiles, filename)
+				queue = append(queue, filename)
 			}
 		}
 	}
 
-	// start with index and recurse through imports
-	recAddTransitiveSet(indexKey)
-
 	// Accumulate Modules sources and imports into lists
 	moduleSrcs := make([]string, 0)
 	moduleImportsList := make([]imports, 0)
-	for src, imports := range moduleSet {
+	for src := range moduleSet {
 		moduleSrcs = append(moduleSrcs, src)
-		moduleImportsList = append(moduleImportsList, imports)
+	}
+	sort.Strings(moduleSrcs)
+	for _, src := range moduleSrcs {
+		moduleImportsList = append(moduleImportsList, moduleSet[src])
 	}
 	moduleImports := flattenImports(moduleImportsList)
 
@@ -646,6 +1036,187 @@ func (lang *JS) makeFolderRule(args moduleRuleArgs, jsConfig *JsConfig) (*import
 	return moduleImports, moduleRule
 }
 
+// makeFolderRules makes a single folder rule, or when there are more srcs than
+// js_collect_all_max_srcs, splits them along subdirectory boundaries into
+// several rules. Subdirectories importing each other stay in the same rule.
+func (lang *JS) makeFolderRules(args moduleRuleArgs, jsConfig *JsConfig) ([]*imports, []*rule.Rule) {
+
+	if jsConfig.CollectAllMaxSrcs <= 0 || len(args.srcs) <= jsConfig.CollectAllMaxSrcs {
+		folderImports, folderRule := lang.makeFolderRule(args, jsConfig)
+		return []*imports{folderImports}, []*rule.Rule{folderRule}
+	}
+
+	// group srcs by directory
+	dirSrcs := make(map[string][]int)
+	for i, src := range args.srcs {
+		dir := path.Dir(src)
+		dirSrcs[dir] = append(dirSrcs[dir], i)
+	}
+
+	// split directories into pieces
+	pieces := splitFolderDirs(".", dirSrcs, jsConfig.CollectAllMaxSrcs)
+
+	// map each src, without extension, to its piece
+	srcPieces := make(map[string]int)
+	for i, piece := range pieces {
+		for _, dir := range piece.dirs {
+			for _, j := range dirSrcs[dir] {
+				src := args.srcs[j]
+				srcPieces[src] = i
+				srcPieces[trimExt(src)] = i
+				if isBarrelFile(path.Base(src)) {
+					srcPieces[dir] = i
+				}
+			}
+		}
+	}
+
+	// build the graph of imports between pieces
+	edges := make(map[int][]int)
+	for i, piece := range pieces {
+		for _, dir := range piece.dirs {
+			for _, j := range dirSrcs[dir] {
+				for imp := range args.imports[j].set {
+					if k, ok := srcPieces[path.Clean(imp)]; ok && k != i {
+						edges[i] = append(edges[i], k)
+					}
+				}
+			}
+		}
+	}
+
+	pieceRoots := make(map[string]int)
+	for i, piece := range pieces {
+		pieceRoots[piece.root] = i
+	}
+
+	allImports := make([]*imports, 0)
+	allRules := make([]*rule.Rule, 0)
+	for _, component := range stronglyConnectedComponents(len(pieces), edges) {
+		// pieces in an import cycle are merged into the first piece
+		pieceSrcs := make([]string, 0)
+		pieceImports := make([]imports, 0)
+		for _, i := range component {
+			for _, dir := range pieces[i].dirs {
+				for _, j := range dirSrcs[dir] {
+					pieceSrcs = append(pieceSrcs, args.srcs[j])
+					pieceImports = append(pieceImports, args.imports[j])
+				}
+			}
+		}
+		sort.Strings(pieceSrcs)
+
+		// merged pieces are named after their common parent directory,
+		// unless it is the root of another piece
+		root := pieces[component[0]].root
+		for _, i := range component[1:] {
+			root = commonDir(root, pieces[i].root)
+		}
+		if k, ok := pieceRoots[root]; ok && !containsInt(component, k) {
+			root = pieces[component[0]].root
+		}
+		if !jsConfig.Quiet && len(pieceSrcs) > jsConfig.CollectAllMaxSrcs {
+			log.Print(Warn("[WARN] %s has %d srcs, more than js_collect_all_max_srcs", path.Join(args.cwd, root), len(pieceSrcs)))
+		}
+
+		name := args.pkgName
+		if root != "." {
+			name = args.pkgName + "_" + strings.ReplaceAll(root, "/", "_")
+		}
+		folderImports, folderRule := lang.makeFolderRule(moduleRuleArgs{
+			pkgName:  name,
+			cwd:      args.cwd,
+			ruleType: args.ruleType,
+			srcs:     pieceSrcs,
+			imports:  pieceImports,
+		}, jsConfig)
+		allImports = append(allImports, folderImports)
+		allRules = append(allRules, folderRule)
+	}
+
+	return allImports, allRules
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// commonDir returns the deepest directory containing two directories
+func commonDir(a string, b string) string {
+	aSegments := strings.Split(a, "/")
+	bSegments := strings.Split(b, "/")
+	common := []string{}
+	for i := 0; i < len(aSegments) && i < len(bSegments) && aSegments[i] == bSegments[i]; i++ {
+		common = append(common, aSegments[i])
+	}
+	if len(common) == 0 {
+		return "."
+	}
+	return path.Join(common...)
+}
+
+// folderPiece is a subtree of a folder rule's srcs, rooted at root
+type folderPiece struct {
+	root string
+	dirs []string
+}
+
+// splitFolderDirs splits the directories under root into pieces of at most
+// maxSrcs srcs. A directory is never split, so pieces may be larger when a
+// single directory has more srcs.
+func splitFolderDirs(root string, dirSrcs map[string][]int, maxSrcs int) []folderPiece {
+
+	// find the directories and srcs under root
+	dirs := make([]string, 0)
+	count := 0
+	for dir, srcs := range dirSrcs {
+		if root == "." || dir == root || strings.HasPrefix(dir, root+"/") {
+			dirs = append(dirs, dir)
+			count += len(srcs)
+		}
+	}
+	sort.Strings(dirs)
+
+	if count <= maxSrcs || len(dirs) == 1 {
+		return []folderPiece{{root: root, dirs: dirs}}
+	}
+
+	pieces := make([]folderPiece, 0)
+
+	// srcs directly in the root are a piece of their own
+	if _, ok := dirSrcs[root]; ok {
+		pieces = append(pieces, folderPiece{root: root, dirs: []string{root}})
+	}
+
+	// each child directory is split further
+	children := make(map[string]bool)
+	for _, dir := range dirs {
+		if dir == root {
+			continue
+		}
+		rel := dir
+		if root != "." {
+			rel = strings.TrimPrefix(dir, root+"/")
+		}
+		children[path.Join(root, strings.SplitN(rel, "/", 2)[0])] = true
+	}
+	childList := make([]string, 0, len(children))
+	for child := range children {
+		childList = append(childList, child)
+	}
+	sort.Strings(childList)
+	for _, child := range childList {
+		pieces = append(pieces, splitFolderDirs(child, dirSrcs, maxSrcs)...)
+	}
+
+	return pieces
+}
+
 func isLocalImport(cwd string, path string) bool {
 
 	// Special case for dot prefix without a folder
@@ -668,6 +1239,14 @@ func isLocalImport(cwd string, path string) bool {
 	return true
 }
 
+func importRefs(imps []imports) []*imports {
+	refs := make([]*imports, len(imps))
+	for i := range imps {
+		refs[i] = &imps[i]
+	}
+	return refs
+}
+
 func flattenImports(imps []imports) *imports {
 
 	aggregatedImports := imports{
@@ -757,6 +1336,73 @@ func (lang *JS) genAllAssets(args language.GenerateArgs, isJSRoot bool, jsConfig
 	return generatedRules, generatedImports
 }
 
+func (lang *JS) applyRuleAttrs(args language.GenerateArgs, jsConfig *JsConfig, generatedRules []*rule.Rule) {
+	for kind, attrs := range jsConfig.RuleAttrs {
+		mappedKind := getKind(args.Config, kind)
+		for _, r := range generatedRules {
+			if r.Kind() != kind && r.Kind() != mappedKind {
+				continue
+			}
+			for attr, value := range attrs {
+				r.SetAttr(attr, value)
+			}
+		}
+	}
+}
+
+// pruneGeneratedRules deletes the existing rules of the kinds generated in
+// this package that were not generated this run
+func (lang *JS) pruneGeneratedRules(args language.GenerateArgs, isJSRoot bool, jsConfig *JsConfig, generatedRules []*rule.Rule) {
+	existingRules := lang.readExistingRules(args, true)
+	for name, r := range existingRules {
+		// leave hand written rules alone
+		if !lang.isGeneratedRule(args, isJSRoot, jsConfig, r) {
+			delete(existingRules, name)
+		}
+	}
+	lang.pruneManagedRules(existingRules, generatedRules)
+}
+
+// isGeneratedRule reports whether an existing rule, of an optional kind, is
+// one that is generated in this package
+func (lang *JS) isGeneratedRule(args language.GenerateArgs, isJSRoot bool, jsConfig *JsConfig, r *rule.Rule) bool {
+	kind := r.Kind()
+	switch kind {
+	case getKind(args.Config, "js_binary"):
+		// binaries are often hand written, only those named after their
+		// entry point in this package are generated
+		entryPoint := r.AttrString("entry_point")
+		return entryPoint != "" && !strings.Contains(entryPoint, ":") && r.Name() == binaryRuleName(entryPoint)
+	case getKind(args.Config, "node_test"):
+		return jsConfig.testRunnerName() == "node"
+	case getKind(args.Config, "playwright_test"), getKind(args.Config, "cypress_test"):
+		// end-to-end tests are only generated next to end-to-end test sources
+		return jsConfig.E2ERunner != "" && kind == getKind(args.Config, e2eRunners[jsConfig.E2ERunner].kind) && lang.hasE2ESources(args, jsConfig)
+	case getKind(args.Config, "storybook"):
+		// the storybook rule is only generated at the JS root
+		return isJSRoot
+	case getKind(args.Config, "next_build"), getKind(args.Config, "next_dev"):
+		// the next rules are only generated at the root of a Next.js app
+		return jsConfig.NextApp != nil && args.Rel == jsConfig.NextApp.root
+	case getKind(args.Config, "vue_component"), getKind(args.Config, "svelte_component"),
+		getKind(args.Config, "astro_component"), getKind(args.Config, "mdx_library"):
+		// components are only generated next to their sources
+		return lang.hasSFCFiles(args, jsConfig, kind)
+	case getKind(args.Config, "ts_config"):
+		return jsConfig.TSConfigDiscovery
+	case getKind(args.Config, "npm_package"):
+		return jsConfig.NpmPackage && r.Name() == npmPackageRuleName
+	case getKind(args.Config, "npm_link_package"):
+		// only links of workspace packages are generated
+		src, err := label.Parse(r.AttrString("src"))
+		return jsConfig.NpmLinkPackages && isJSRoot && err == nil && src.Name == npmPackageRuleName
+	case getKind(args.Config, "npm_link_all_packages"):
+		// usually hand written, generated ones are merged but never deleted
+		return false
+	}
+	return true
+}
+
 func (lang *JS) readExistingRules(args language.GenerateArgs, managedOnly bool) map[string]*rule.Rule {
 	existingRules := make(map[string]*rule.Rule)
 
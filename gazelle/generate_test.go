@@ -17,6 +17,7 @@
 package js
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -26,3 +27,50 @@ func TestPattern(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestSplitFolderDirs(t *testing.T) {
+	dirSrcs := map[string][]int{
+		".":          {0},
+		"core":       {1, 2, 3},
+		"features/a": {4, 5},
+		"features/b": {6},
+		"big/x":      {7, 8, 9, 10},
+	}
+	got := splitFolderDirs(".", dirSrcs, 3)
+	want := []folderPiece{
+		{root: ".", dirs: []string{"."}},
+		{root: "big", dirs: []string{"big/x"}},
+		{root: "core", dirs: []string{"core"}},
+		{root: "features", dirs: []string{"features/a", "features/b"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Inequality.\ngot  %#v;\nwant %#v", got, want)
+	}
+}
+
+func TestCommonDir(t *testing.T) {
+	for _, tc := range []struct{ a, b, want string }{
+		{"cyclic/x", "cyclic/y", "cyclic"},
+		{"a/b/c", "a/b", "a/b"},
+		{"core", "features", "."},
+		{".", "core", "."},
+	} {
+		if got := commonDir(tc.a, tc.b); got != tc.want {
+			t.Errorf("commonDir(%q, %q) = %q, want %q", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestCompiledEntryPoint(t *testing.T) {
+	for _, tc := range []struct{ baseName, want string }{
+		{"cli.ts", "cli.js"},
+		{"bin/cli.tsx", "bin/cli.js"},
+		{"cli.mts", "cli.mjs"},
+		{"cli.cts", "cli.cjs"},
+		{"server.js", "server.js"},
+	} {
+		if got := compiledEntryPoint(tc.baseName); got != tc.want {
+			t.Errorf("compiledEntryPoint(%q) = %q, want %q", tc.baseName, got, tc.want)
+		}
+	}
+}
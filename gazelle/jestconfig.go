@@ -0,0 +1,291 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const rootDirToken = "<rootDir>"
+
+// jestConfig contains the parts of a JSON jest config used to resolve the
+// deps of tests
+type jestConfig struct {
+	moduleNameMappers []jestModuleNameMapper
+	// modules loaded by jest for every test, as repository paths or npm
+	// package names
+	setupModules []jestModule
+}
+
+type jestModuleNameMapper struct {
+	pattern *regexp.Regexp
+	target  jestModule
+}
+
+type jestModule struct {
+	name  string
+	local bool
+}
+
+type jestConfigJSON struct {
+	RootDir            string                     `json:"rootDir"`
+	ModuleNameMapper   json.RawMessage            `json:"moduleNameMapper"`
+	SetupFiles         []string                   `json:"setupFiles"`
+	SetupFilesAfterEnv []string                   `json:"setupFilesAfterEnv"`
+	TestEnvironment    string                     `json:"testEnvironment"`
+	Preset             string                     `json:"preset"`
+	Transform          map[string]json.RawMessage `json:"transform"`
+	Reporters          []json.RawMessage          `json:"reporters"`
+}
+
+// jestBuiltinReporters are reporters named in a jest config that are not
+// modules
+var jestBuiltinReporters = []string{"default", "summary", "github-actions"}
+
+// readJestConfig reads a JSON jest config file, or the "jest" key of a
+// package.json file, in the package rel
+func readJestConfig(repoRoot string, rel string, baseName string) (*jestConfig, error) {
+	data, err := os.ReadFile(path.Join(repoRoot, rel, baseName))
+	if err != nil {
+		return nil, err
+	}
+	if baseName == "package.json" {
+		packageJSON := struct {
+			Jest json.RawMessage `json:"jest"`
+		}{}
+		if err := json.Unmarshal(data, &packageJSON); err != nil {
+			return nil, err
+		}
+		data = packageJSON.Jest
+	}
+	configJSON := jestConfigJSON{}
+	if err := json.Unmarshal(stripJSONC(data), &configJSON); err != nil {
+		return nil, err
+	}
+
+	rootDir := path.Join(rel, configJSON.RootDir)
+	config := &jestConfig{}
+
+	// keys are regular expressions, applied in order
+	mappers, err := readOrderedObject(configJSON.ModuleNameMapper)
+	if err != nil {
+		return nil, fmt.Errorf("moduleNameMapper: %v", err)
+	}
+	for _, mapper := range mappers {
+		re, err := regexp.Compile(mapper.key)
+		if err != nil {
+			return nil, fmt.Errorf("moduleNameMapper %s: %v", mapper.key, err)
+		}
+		// values are a module or a list of modules tried in order
+		var target string
+		var targets []string
+		if err := json.Unmarshal(mapper.value, &target); err != nil {
+			if err := json.Unmarshal(mapper.value, &targets); err != nil || len(targets) == 0 {
+				return nil, fmt.Errorf("moduleNameMapper %s: expected a string or a list of strings", mapper.key)
+			}
+			target = targets[0]
+		}
+		// jest uses $1 for groups, which may be followed by a letter
+		target = regexp.MustCompile(`\$(\d+)`).ReplaceAllString(target, "$${$1}")
+		config.moduleNameMappers = append(config.moduleNameMappers, jestModuleNameMapper{
+			pattern: re,
+			target:  jestModuleOf(rootDir, target),
+		})
+	}
+
+	for _, setupFile := range append(configJSON.SetupFiles, configJSON.SetupFilesAfterEnv...) {
+		config.setupModules = append(config.setupModules, jestModuleOf(rootDir, setupFile))
+	}
+
+	switch configJSON.TestEnvironment {
+	case "", "node":
+		// the node environment ships with jest
+	case "jsdom":
+		config.setupModules = append(config.setupModules, jestModule{name: "jest-environment-" + configJSON.TestEnvironment})
+	default:
+		config.setupModules = append(config.setupModules, jestModuleOf(rootDir, configJSON.TestEnvironment))
+	}
+
+	if configJSON.Preset != "" {
+		config.setupModules = append(config.setupModules, jestModuleOf(rootDir, configJSON.Preset))
+	}
+	transformers := make([]string, 0, len(configJSON.Transform))
+	for pattern, value := range configJSON.Transform {
+		transformer, err := jestModuleOption(value)
+		if err != nil {
+			return nil, fmt.Errorf("transform %s: %v", pattern, err)
+		}
+		transformers = append(transformers, transformer)
+	}
+	sort.Strings(transformers)
+	for _, transformer := range transformers {
+		config.setupModules = append(config.setupModules, jestModuleOf(rootDir, transformer))
+	}
+	for _, value := range configJSON.Reporters {
+		reporter, err := jestModuleOption(value)
+		if err != nil {
+			return nil, fmt.Errorf("reporters: %v", err)
+		}
+		if !isExcludedDep(jestBuiltinReporters, reporter) {
+			config.setupModules = append(config.setupModules, jestModuleOf(rootDir, reporter))
+		}
+	}
+
+	return config, nil
+}
+
+// jestModuleOption reads a module configured by name, or by name and options
+// like ["jest-junit", {"outputDirectory": "reports"}]
+func jestModuleOption(value json.RawMessage) (string, error) {
+	var module string
+	if err := json.Unmarshal(value, &module); err == nil {
+		return module, nil
+	}
+	var moduleWithOptions []json.RawMessage
+	if err := json.Unmarshal(value, &moduleWithOptions); err != nil || len(moduleWithOptions) == 0 {
+		return "", fmt.Errorf("expected a module name, or a module name and options")
+	}
+	if err := json.Unmarshal(moduleWithOptions[0], &module); err != nil {
+		return "", fmt.Errorf("expected a module name, or a module name and options")
+	}
+	return module, nil
+}
+
+// discoverJestConfig reads the nearest jest config discovered in the package
+// rel, when it is JSON
+func (jsConfig *JsConfig) discoverJestConfig(repoRoot string, rel string) {
+	if !jsConfig.FindTestConfigs || jsConfig.testRunnerName() != "jest" {
+		return
+	}
+	runner := testRunners["jest"]
+	dir := path.Join(repoRoot, rel)
+	configFile, ok := runner.findConfigFile(dir)
+	if !ok && runner.hasPackageConfig(dir) {
+		configFile, ok = "package.json", true
+	}
+	if !ok {
+		return
+	}
+
+	jsConfig.JestConfig = nil
+	if configFile == "package.json" || path.Ext(configFile) == ".json" {
+		jestConfig, err := readJestConfig(repoRoot, rel, configFile)
+		if err != nil {
+			log.Fatalf(Err("failed to parse %s: %v", path.Join(rel, configFile), err))
+		}
+		jsConfig.JestConfig = jestConfig
+	}
+}
+
+// jestModuleOf returns the module of a path in a jest config, relative to
+// rootDir, or an npm package
+func jestModuleOf(rootDir string, module string) jestModule {
+	if strings.HasPrefix(module, rootDirToken) {
+		return jestModule{name: path.Join(rootDir, strings.TrimPrefix(module, rootDirToken)), local: true}
+	}
+	if strings.HasPrefix(module, ".") {
+		return jestModule{name: path.Join(rootDir, module), local: true}
+	}
+	return jestModule{name: module}
+}
+
+// mapImport applies the first matching moduleNameMapper to an import of a
+// test in the package from
+func (config *jestConfig) mapImport(from string, imp string) string {
+	for _, mapper := range config.moduleNameMappers {
+		match := mapper.pattern.FindStringSubmatchIndex(imp)
+		if match == nil {
+			continue
+		}
+		// the whole import is replaced, with groups expanded
+		mapped := string(mapper.pattern.ExpandString(nil, mapper.target.name, imp, match))
+		if mapper.target.local {
+			return relativeImport(from, mapped)
+		}
+		return mapped
+	}
+	return imp
+}
+
+// setupImports returns the imports of the setup modules for a test in the
+// package from
+func (config *jestConfig) setupImports(from string) []string {
+	imports := make([]string, 0, len(config.setupModules))
+	for _, module := range config.setupModules {
+		if module.local {
+			imports = append(imports, relativeImport(from, module.name))
+		} else {
+			imports = append(imports, module.name)
+		}
+	}
+	return imports
+}
+
+type jsonObjectEntry struct {
+	key   string
+	value json.RawMessage
+}
+
+// readOrderedObject returns the entries of a JSON object in order
+func readOrderedObject(data json.RawMessage) ([]jsonObjectEntry, error) {
+	entries := []jsonObjectEntry{}
+	if len(data) == 0 {
+		return entries, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		entry := jsonObjectEntry{key: token.(string)}
+		if err := decoder.Decode(&entry.value); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// relativeImport returns the import of a repository path from the package from
+func relativeImport(from string, target string) string {
+	fromDirs := strings.Split(from, "/")
+	if from == "" {
+		fromDirs = []string{}
+	}
+	targetDirs := strings.Split(target, "/")
+	common := 0
+	for common < len(fromDirs) && common < len(targetDirs)-1 && fromDirs[common] == targetDirs[common] {
+		common++
+	}
+	relative := strings.Repeat("../", len(fromDirs)-common) + strings.Join(targetDirs[common:], "/")
+	if !strings.HasPrefix(relative, "../") {
+		relative = "./" + relative
+	}
+	return relative
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRelativeImport(t *testing.T) {
+	for _, tc := range []struct {
+		from, target, want string
+	}{
+		{from: "", target: "src/util", want: "./src/util"},
+		{from: "app", target: "src/util", want: "../src/util"},
+		{from: "app", target: "app/util", want: "./util"},
+		{from: "app/nested", target: "app/util", want: "../util"},
+		{from: "app", target: "jest.setup", want: "../jest.setup"},
+	} {
+		t.Run(tc.from+":"+tc.target, func(t *testing.T) {
+			if got := relativeImport(tc.from, tc.target); got != tc.want {
+				t.Errorf("relativeImport(%q, %q) = %q, want %q", tc.from, tc.target, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJestModuleOption(t *testing.T) {
+	for _, tc := range []struct {
+		value, want string
+	}{
+		{value: `"babel-jest"`, want: "babel-jest"},
+		{value: `["jest-junit", {"outputDirectory": "reports"}]`, want: "jest-junit"},
+		{value: `["<rootDir>/reporter.js"]`, want: "<rootDir>/reporter.js"},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := jestModuleOption(json.RawMessage(tc.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("jestModuleOption(%s) = %q, want %q", tc.value, got, tc.want)
+			}
+		})
+	}
+
+	if _, err := jestModuleOption(json.RawMessage(`{}`)); err == nil {
+		t.Errorf("jestModuleOption({}) should fail")
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// testTimeouts are the timeouts of bazel tests in seconds, by name, with the
+// size whose default timeout they are
+var testTimeouts = []struct {
+	name    string
+	size    string
+	seconds float64
+}{
+	{"short", "small", 60},
+	{"moderate", "medium", 300},
+	{"long", "large", 900},
+	{"eternal", "enormous", 3600},
+}
+
+// timeoutMargin is the factor applied to historical durations to pick a
+// timeout, to leave room for slower runs
+const timeoutMargin = 2
+
+const defaultJestSecondsPerShard = 60
+
+type junitTestSuites struct {
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	File      string           `xml:"file,attr"`
+	Time      float64          `xml:"time,attr"`
+	TestCases []junitTestCase  `xml:"testcase"`
+	Suites    []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestCase struct {
+	File string  `xml:"file,attr"`
+	Time float64 `xml:"time,attr"`
+}
+
+// readJUnitReports reads the duration in seconds of each test file from the
+// JUnit XML reports in a directory, keeping the longest run of each file
+func readJUnitReports(dir string) (map[string]float64, error) {
+	reports, err := filepath.Glob(path.Join(dir, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+	durations := make(map[string]float64)
+	for _, report := range reports {
+		data, err := os.ReadFile(report)
+		if err != nil {
+			return nil, err
+		}
+		var suites junitTestSuites
+		if err := xml.Unmarshal(data, &suites); err != nil {
+			// a report may be a single suite
+			var suite junitTestSuite
+			if err := xml.Unmarshal(data, &suite); err != nil {
+				return nil, fmt.Errorf("%s: %v", report, err)
+			}
+			suites.TestSuites = []junitTestSuite{suite}
+		}
+		reportDurations := make(map[string]float64)
+		for _, suite := range suites.TestSuites {
+			suite.addDurations(reportDurations)
+		}
+		for file, duration := range reportDurations {
+			durations[file] = math.Max(durations[file], duration)
+		}
+	}
+	return durations, nil
+}
+
+// addDurations adds the time of the suite to the test files it ran, named by
+// the file attribute of its test cases, of itself, or by its name
+func (suite *junitTestSuite) addDurations(durations map[string]float64) {
+	for _, nested := range suite.Suites {
+		nested.addDurations(durations)
+	}
+	counted := false
+	for _, testCase := range suite.TestCases {
+		if testCase.File != "" {
+			durations[path.Clean(filepath.ToSlash(testCase.File))] += testCase.Time
+			counted = true
+		}
+	}
+	if counted {
+		return
+	}
+	file := suite.File
+	if file == "" && (jsTestExtensionsPattern.MatchString(suite.Name) || tsTestExtensionsPattern.MatchString(suite.Name)) {
+		file = suite.Name
+	}
+	if file != "" {
+		durations[path.Clean(filepath.ToSlash(file))] += suite.Time
+	}
+}
+
+// testDuration returns the historical duration of a test file, given by its
+// path in the repository. Reports may name files by absolute path or relative
+// to a sub directory, the reported file sharing the longest path with it is
+// used, and the shared path must include a directory.
+func (jsConfig *JsConfig) testDuration(file string) (float64, bool) {
+	if duration, ok := jsConfig.TestDurations[file]; ok {
+		return duration, true
+	}
+	best := ""
+	bestLen := 0
+	for reported := range jsConfig.TestDurations {
+		var shared string
+		switch {
+		case strings.HasSuffix(reported, "/"+file):
+			shared = file
+		case strings.HasSuffix(file, "/"+reported):
+			shared = reported
+		default:
+			continue
+		}
+		if !strings.Contains(shared, "/") {
+			continue
+		}
+		if len(shared) > bestLen || (len(shared) == bestLen && reported < best) {
+			best = reported
+			bestLen = len(shared)
+		}
+	}
+	if bestLen == 0 {
+		return 0, false
+	}
+	return jsConfig.TestDurations[best], true
+}
+
+// addDurationAttributes sets the shard_count, size and timeout of a test rule
+// from the historical durations of its sources, and reports whether there were
+// any
+func (jsConfig *JsConfig) addDurationAttributes(rel string, r *rule.Rule) bool {
+	srcs := r.AttrStrings("srcs")
+	total := 0.0
+	longest := 0.0
+	known := 0
+	for _, src := range srcs {
+		if duration, ok := jsConfig.testDuration(path.Join(rel, src)); ok {
+			total += duration
+			longest = math.Max(longest, duration)
+			known++
+		}
+	}
+	if known == 0 {
+		return false
+	}
+	// sources without history are assumed to take the mean time of the others
+	total += total / float64(known) * float64(len(srcs)-known)
+
+	secondsPerShard := float64(jsConfig.JestSecsPerShard)
+	if secondsPerShard <= 0 {
+		secondsPerShard = defaultJestSecondsPerShard
+	}
+	shardCount := int(math.Ceil(total / secondsPerShard))
+	if shardCount > len(srcs) {
+		// files are not split between shards
+		shardCount = len(srcs)
+	}
+	if shardCount > 1 {
+		r.SetAttr("shard_count", shardCount)
+	} else {
+		shardCount = 1
+	}
+
+	// a shard runs at least the longest file
+	shardDuration := math.Max(total/float64(shardCount), longest) * timeoutMargin
+	for _, timeout := range testTimeouts {
+		if shardDuration <= timeout.seconds || timeout.name == "eternal" {
+			r.SetAttr("size", timeout.size)
+			r.SetAttr("timeout", timeout.name)
+			break
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import "testing"
+
+func TestTestDuration(t *testing.T) {
+	jsConfig := NewJsConfig()
+	jsConfig.TestDurations = map[string]float64{
+		"a.test.js":                      1,
+		"app/index.test.js":              2,
+		"/ci/repo/src/app/index.test.js": 3,
+		"lib/index.test.js":              4,
+		"/ci/repo/pkg/b.test.js":         5,
+		"/other/pkg/b.test.js":           6,
+	}
+	for _, tc := range []struct {
+		file string
+		want float64
+		ok   bool
+	}{
+		{file: "a.test.js", want: 1, ok: true},
+		{file: "src/a.test.js", ok: false},
+		{file: "src/app/index.test.js", want: 3, ok: true},
+		{file: "web/app/index.test.js", want: 2, ok: true},
+		{file: "pkg/b.test.js", want: 5, ok: true},
+		{file: "lib/index.test.js", want: 4, ok: true},
+		{file: "other/index.test.js", ok: false},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			got, ok := jsConfig.testDuration(tc.file)
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("testDuration(%q) = %v, %v; want %v, %v", tc.file, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
@@ -39,6 +39,18 @@ func (*JS) Kinds() map[string]rule.KindInfo {
 				"data": true,
 			},
 		},
+		"js_binary": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"entry_point": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"entry_point": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"data": true,
+			},
+		},
 		"ts_project": {
 			MatchAny: false,
 			NonEmptyAttrs: map[string]bool{
@@ -53,6 +65,18 @@ func (*JS) Kinds() map[string]rule.KindInfo {
 				"data": true,
 			},
 		},
+		"ts_config": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"src": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"src": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+			},
+		},
 		"ts_definition": {
 			MatchAny: false,
 			NonEmptyAttrs: map[string]bool{
@@ -81,6 +105,76 @@ func (*JS) Kinds() map[string]rule.KindInfo {
 				"data": true,
 			},
 		},
+		"vitest_test": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+				"tags": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"mocha_test": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+				"tags": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"node_test": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+				"tags": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"playwright_test": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+				"tags": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"cypress_test": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+				"tags": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
 		"web_asset": {
 			MatchAny: false,
 			NonEmptyAttrs: map[string]bool{
@@ -101,5 +195,110 @@ func (*JS) Kinds() map[string]rule.KindInfo {
 				"tags": true,
 			},
 		},
+		"vue_component": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+				"lang": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"svelte_component": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+				"lang": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"astro_component": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"mdx_library": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"storybook": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"stories": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"stories": true,
+			},
+		},
+		"next_build": {
+			MatchAny: false,
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"next_dev": {
+			MatchAny: false,
+			MergeableAttrs: map[string]bool{
+				"srcs": true,
+			},
+			ResolveAttrs: map[string]bool{
+				"deps": true,
+				"data": true,
+			},
+		},
+		"npm_package": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"srcs": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"srcs":    true,
+				"package": true,
+			},
+		},
+		"npm_link_package": {
+			MatchAny: false,
+			NonEmptyAttrs: map[string]bool{
+				"src": true,
+			},
+			MergeableAttrs: map[string]bool{
+				"src": true,
+			},
+		},
+		"npm_link_all_packages": {
+			MatchAny: true,
+		},
 	}
 }
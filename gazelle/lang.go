@@ -30,6 +30,13 @@ func (*JS) Name() string {
 }
 
 type JS struct {
+	language.BaseLifecycleManager
+	// dependencyGraph records resolved dependencies between packages, to
+	// report cycles after resolution
+	dependencyGraph *dependencyGraph
+	// testOnlyRules records the labels of testonly rules, to report the
+	// rules depending on them
+	testOnlyRules map[string]bool
 }
 
 func NewLanguage() language.Language {
@@ -0,0 +1,70 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var mdxCodeFencePattern = regexp.MustCompile("(?ms)^[ \t]*(```|~~~).*?^[ \t]*(```|~~~)[ \t]*$")
+var mdxParagraphPattern = regexp.MustCompile(`\n[ \t]*\n`)
+var mdxESMPattern = regexp.MustCompile(`^(import|export)\s`)
+
+var astroFrontmatterPattern = regexp.MustCompile(`(?s)\A\s*---[ \t]*\r?\n(.*?)\r?\n---`)
+
+// ParseMDX returns the imports of the ESM blocks of an MDX file, the
+// paragraphs starting with import or export outside of code fences
+func ParseMDX(data []byte) ([]string, error) {
+	imports := make([]string, 0)
+	content := mdxCodeFencePattern.ReplaceAll(data, nil)
+	for _, paragraph := range mdxParagraphPattern.Split(string(content), -1) {
+		block := []byte(strings.TrimSpace(paragraph))
+		if !mdxESMPattern.Match(block) {
+			continue
+		}
+		blockImports, _, _, err := ParseJS(block)
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, blockImports...)
+	}
+	sort.Strings(imports)
+	return imports, nil
+}
+
+// ParseAstro returns the imports of the frontmatter script of an Astro
+// component and of its <script> blocks
+func ParseAstro(data []byte) ([]string, error) {
+	imports := make([]string, 0)
+	if frontmatter := astroFrontmatterPattern.FindSubmatch(data); frontmatter != nil {
+		frontmatterImports, _, _, err := ParseJS(indentPattern.ReplaceAll(frontmatter[1], nil))
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, frontmatterImports...)
+		data = data[len(frontmatter[0]):]
+	}
+	scriptImports, _, _, err := ParseSFC(data)
+	if err != nil {
+		return nil, err
+	}
+	imports = append(imports, scriptImports...)
+	sort.Strings(imports)
+	return imports, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"path"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+const mocksDirName = "__mocks__"
+
+// mockRuleSuffix ends the names of manual mock rules, so that they do not
+// collide with the rules of the modules they mock
+const mockRuleSuffix = "_mock"
+
+// mockImportPrefix prefixes the import specs of manual mocks, so that they are
+// only found when looking up the mocks of a module
+const mockImportPrefix = "mock:"
+
+// mockImportNpmPrefix prefixes the import specs of manual mocks of npm
+// packages
+const mockImportNpmPrefix = "mock:npm:"
+
+// splitMocksDir returns the directory containing the "__mocks__" directory of
+// a package, and the path of the package below it
+func splitMocksDir(rel string) (string, string, bool) {
+	dirs := strings.Split(rel, "/")
+	for i, dir := range dirs {
+		if dir == mocksDirName {
+			return path.Join(dirs[:i]...), path.Join(dirs[i+1:]...), true
+		}
+	}
+	return "", "", false
+}
+
+func isMocksDir(rel string) bool {
+	_, _, ok := splitMocksDir(rel)
+	return ok
+}
+
+// setMockAttrs names the library rules generated for manual mocks like
+// "api_mock" and marks them as testonly
+func (lang *JS) setMockAttrs(args language.GenerateArgs, generatedRules []*rule.Rule) {
+	if !isMocksDir(args.Rel) {
+		return
+	}
+	for _, r := range generatedRules {
+		if r.Kind() == getKind(args.Config, "ts_project") || r.Kind() == getKind(args.Config, "js_library") {
+			if r.Name() != "package_json" {
+				r.SetName(r.Name() + mockRuleSuffix)
+				r.SetAttr("testonly", true)
+			}
+		}
+	}
+}
+
+// mockImportSpecs returns the import specs of a manual mock: the module next
+// to the "__mocks__" directory, and an npm package for mocks in the JS root
+func (lang *JS) mockImportSpecs(jsConfig *JsConfig, pkg string, srcs []string) []resolve.ImportSpec {
+	parentDir, subDir, ok := splitMocksDir(pkg)
+	if !ok {
+		return nil
+	}
+	jsRoot := jsConfig.JSRoot
+	if jsRoot == "." {
+		jsRoot = ""
+	}
+
+	importSpecs := make([]resolve.ImportSpec, 0)
+	for _, src := range srcs {
+		mockedModule := path.Join(subDir, trimExt(src))
+		importSpecs = append(importSpecs, resolve.ImportSpec{
+			Lang: lang.Name(),
+			Imp:  mockImportPrefix + path.Join(parentDir, mockedModule),
+		})
+		if parentDir == jsRoot {
+			importSpecs = append(importSpecs, resolve.ImportSpec{
+				Lang: lang.Name(),
+				Imp:  mockImportNpmPrefix + mockedModule,
+			})
+		}
+	}
+	return importSpecs
+}
+
+// resolveMocks adds the manual mocks of the modules imported, or mocked, by a
+// test to its deps
+func (lang *JS) resolveMocks(mockedImports map[string]bool, depSet map[string]bool, c *config.Config, ix *resolve.RuleIndex, from label.Label) {
+	for name, isNpm := range mockedImports {
+		mockImport := mockImportNpmPrefix + name
+		if !isNpm {
+			if !strings.HasPrefix(name, ".") {
+				continue
+			}
+			mockImport = mockImportPrefix + trimExt(path.Join(from.Pkg, name))
+		}
+
+		resolveResult := lang.tryResolve(mockImport, c, ix, from)
+		if resolveResult.err == nil && !resolveResult.selfImport && resolveResult.label != label.NoLabel {
+			depSet[resolveResult.label.Rel(from.Repo, from.Pkg).String()] = true
+		}
+	}
+}
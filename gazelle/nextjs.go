@@ -0,0 +1,215 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// nextBuildRuleName is the name of the rule running `next build` at the root
+// of a Next.js app
+const nextBuildRuleName = "next"
+
+// nextDevRuleName is the name of the rule running `next dev` at the root of a
+// Next.js app
+const nextDevRuleName = "next_dev"
+
+// nextPublicRuleName is the name of the assets rule of the public directory
+const nextPublicRuleName = "assets"
+
+const nextPublicDir = "public"
+
+// nextConfigFiles are the config files marking the root of a Next.js app
+var nextConfigFiles = []string{
+	"next.config.js",
+	"next.config.mjs",
+	"next.config.cjs",
+	"next.config.ts",
+}
+
+// nextRouteDirs are the directories of routes, relative to the app root
+var nextRouteDirs = []string{
+	"pages",
+	"app",
+	"src/pages",
+	"src/app",
+}
+
+// nextApp is shared by all packages of a Next.js app to collect its sources
+type nextApp struct {
+	root       string
+	configFile string
+	srcs       map[string]bool
+}
+
+func findNextConfig(dir string) (string, bool) {
+	for _, configFile := range nextConfigFiles {
+		if info, err := os.Stat(path.Join(dir, configFile)); err == nil && !info.IsDir() {
+			return configFile, true
+		}
+	}
+	return "", false
+}
+
+// configureNextApp finds the root of a Next.js app and collects each of its
+// route directories into a single library
+func (jsConfig *JsConfig) configureNextApp(repoRoot, rel string) {
+	if !jsConfig.NextJS {
+		jsConfig.NextApp = nil
+		return
+	}
+	if configFile, ok := findNextConfig(path.Join(repoRoot, rel)); ok {
+		jsConfig.NextApp = &nextApp{
+			root:       rel,
+			configFile: configFile,
+			srcs:       make(map[string]bool),
+		}
+		return
+	}
+	if jsConfig.isNextRouteDir(rel) {
+		jsConfig.CollectBarrels = false
+		jsConfig.CollectDirectory = false
+		jsConfig.CollectAllRoot = rel
+		jsConfig.CollectAll = true
+		jsConfig.CollectAllSources = make(map[string]bool)
+	}
+}
+
+func (jsConfig *JsConfig) isNextRouteDir(rel string) bool {
+	if jsConfig.NextApp == nil {
+		return false
+	}
+	for _, dir := range nextRouteDirs {
+		if rel == path.Join(jsConfig.NextApp.root, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (jsConfig *JsConfig) isNextPublicDir(rel string) bool {
+	if jsConfig.NextApp == nil {
+		return false
+	}
+	publicDir := path.Join(jsConfig.NextApp.root, nextPublicDir)
+	return rel == publicDir || strings.HasPrefix(rel, publicDir+"/")
+}
+
+// isNextAppFile reports files of the app root that belong to the next rules
+// rather than to the sources of the package
+func (jsConfig *JsConfig) isNextAppFile(rel, baseName string) bool {
+	if jsConfig.NextApp == nil || rel != jsConfig.NextApp.root {
+		return false
+	}
+	return baseName == jsConfig.NextApp.configFile || baseName == "next-env.d.ts"
+}
+
+// genNextPublic generates a single assets rule of all the files of the public
+// directory of a Next.js app
+func (lang *JS) genNextPublic(args language.GenerateArgs, jsConfig *JsConfig) ([]*rule.Rule, []interface{}) {
+	if args.Rel != path.Join(jsConfig.NextApp.root, nextPublicDir) {
+		return nil, nil
+	}
+
+	srcs := listPackageFiles(args)
+	if len(srcs) == 0 {
+		return nil, nil
+	}
+
+	r := rule.NewRule(getKind(args.Config, "web_assets"), nextPublicRuleName)
+	r.SetAttr("srcs", srcs)
+	if len(jsConfig.Visibility.Labels) > 0 {
+		r.SetAttr("visibility", jsConfig.Visibility.Labels)
+	}
+
+	// record the assets for the next rules
+	jsConfig.NextApp.srcs[fmt.Sprintf("//%s:%s", args.Rel, nextPublicRuleName)] = true
+
+	return []*rule.Rule{r}, []interface{}{&noImports}
+}
+
+// recordNextRoutes records the libraries of a route directory for the next
+// rules
+func (lang *JS) recordNextRoutes(args language.GenerateArgs, jsConfig *JsConfig, generatedRules []*rule.Rule) {
+	if !jsConfig.isNextRouteDir(args.Rel) {
+		return
+	}
+	for _, r := range generatedRules {
+		switch r.Kind() {
+		case getKind(args.Config, "ts_project"), getKind(args.Config, "js_library"):
+			if isStoriesRule(r) || isTestOnly(r) {
+				continue
+			}
+			jsConfig.NextApp.srcs[fmt.Sprintf("//%s:%s", args.Rel, r.Name())] = true
+		}
+	}
+}
+
+// genNextApp generates the `next build` and `next dev` rules at the root of a
+// Next.js app, from the routes and assets collected below it
+func (lang *JS) genNextApp(args language.GenerateArgs, jsConfig *JsConfig) ([]*rule.Rule, []interface{}) {
+	if jsConfig.NextApp == nil || args.Rel != jsConfig.NextApp.root {
+		return nil, nil
+	}
+
+	srcs := make([]string, 0, len(jsConfig.NextApp.srcs))
+	for lbl := range jsConfig.NextApp.srcs {
+		srcs = append(srcs, lbl)
+	}
+	sort.Strings(srcs)
+
+	configFile := jsConfig.NextApp.configFile
+	fileImports, _, _ := readFileAndParse(path.Join(args.Dir, configFile), args.Rel)
+	fileImports.set["next"] = true
+
+	data := []string{configFile}
+	for _, fileName := range []string{"next-env.d.ts", tsConfigFileName} {
+		if _, err := os.Stat(path.Join(args.Dir, fileName)); err == nil {
+			data = append(data, fileName)
+		}
+	}
+	sort.Strings(data)
+	if _, err := os.Stat(path.Join(args.Dir, "package.json")); err == nil {
+		data = append(data, ":package_json")
+	}
+
+	generatedRules := make([]*rule.Rule, 0)
+	generatedImports := make([]interface{}, 0)
+	for _, kindName := range [][2]string{
+		{"next_build", nextBuildRuleName},
+		{"next_dev", nextDevRuleName},
+	} {
+		r := rule.NewRule(getKind(args.Config, kindName[0]), kindName[1])
+		if len(srcs) > 0 {
+			r.SetAttr("srcs", srcs)
+		}
+		addExplicitData(r, data)
+		if len(jsConfig.Visibility.Labels) > 0 {
+			r.SetAttr("visibility", jsConfig.Visibility.Labels)
+		}
+		generatedRules = append(generatedRules, r)
+		generatedImports = append(generatedImports, fileImports)
+	}
+	return generatedRules, generatedImports
+}
@@ -0,0 +1,230 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+const npmPackageRuleName = "pkg"
+
+// readPackageName returns the "name" field of a package.json file
+func readPackageName(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	packageJSON := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(data, &packageJSON); err != nil {
+		return "", err
+	}
+	return packageJSON.Name, nil
+}
+
+// npmPackageRoot is shared by all packages below a workspace package to
+// collect its libraries
+type npmPackageRoot struct {
+	root string
+	srcs map[string]bool
+}
+
+// npmLinkAllRuleName is the name of the rule linking the npm packages of a
+// pnpm workspace
+const npmLinkAllRuleName = "node_modules"
+
+// configureNpmPackage finds workspace packages, by their package.json below
+// the JS root, and whether npm packages are linked by npm_link_all_packages
+func (jsConfig *JsConfig) configureNpmPackage(c *config.Config, rel string, f *rule.File) {
+	if path.Clean(rel) == path.Clean(jsConfig.JSRoot) {
+		jsConfig.NpmPackageRoot = nil
+		jsConfig.NpmLinkAll = hasRuleOfKind(f, getKind(c, "npm_link_all_packages"))
+		if _, err := os.Stat(path.Join(c.RepoRoot, rel, "pnpm-lock.yaml")); err == nil {
+			jsConfig.NpmLinkAll = true
+		}
+		return
+	}
+	if !jsConfig.NpmPackage {
+		return
+	}
+	if info, err := os.Stat(path.Join(c.RepoRoot, rel, "package.json")); err == nil && !info.IsDir() {
+		jsConfig.NpmPackageRoot = &npmPackageRoot{
+			root: rel,
+			srcs: make(map[string]bool),
+		}
+	}
+}
+
+func hasRuleOfKind(f *rule.File, kind string) bool {
+	if f == nil {
+		return false
+	}
+	for _, r := range f.Rules {
+		if r.Kind() == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// recordNpmPackageSrcs records the libraries of a package for the npm_package
+// rule of the workspace package containing it
+func (lang *JS) recordNpmPackageSrcs(args language.GenerateArgs, jsConfig *JsConfig, generatedRules []*rule.Rule) {
+	if !jsConfig.NpmPackage || jsConfig.NpmPackageRoot == nil {
+		return
+	}
+	for _, r := range generatedRules {
+		if r.Kind() != getKind(args.Config, "ts_project") && r.Kind() != getKind(args.Config, "js_library") {
+			continue
+		}
+		if r.Name() == "package_json" || isStoriesRule(r) || isTestOnly(r) {
+			continue
+		}
+		if args.Rel == jsConfig.NpmPackageRoot.root {
+			jsConfig.NpmPackageRoot.srcs[":"+r.Name()] = true
+		} else {
+			jsConfig.NpmPackageRoot.srcs[fmt.Sprintf("//%s:%s", args.Rel, r.Name())] = true
+		}
+	}
+}
+
+// genNpmPackage generates an "npm_package" rule for a workspace package
+// containing its package.json and the libraries of all its packages
+func (lang *JS) genNpmPackage(args language.GenerateArgs, jsConfig *JsConfig) ([]*rule.Rule, []interface{}) {
+	if !jsConfig.NpmPackage || jsConfig.NpmPackageRoot == nil || args.Rel != jsConfig.NpmPackageRoot.root {
+		return []*rule.Rule{}, []interface{}{}
+	}
+
+	packageName, err := readPackageName(path.Join(args.Dir, "package.json"))
+	if err != nil {
+		log.Fatalf(Err("failed to parse %s: %v", path.Join(args.Rel, "package.json"), err))
+	}
+
+	srcs := make([]string, 0, len(jsConfig.NpmPackageRoot.srcs)+1)
+	for src := range jsConfig.NpmPackageRoot.srcs {
+		srcs = append(srcs, src)
+	}
+	sort.Strings(srcs)
+	srcs = append([]string{"package.json"}, srcs...)
+
+	r := rule.NewRule(getKind(args.Config, "npm_package"), npmPackageRuleName)
+	r.SetAttr("srcs", srcs)
+	if packageName != "" {
+		r.SetAttr("package", packageName)
+	} else if !jsConfig.Quiet {
+		log.Print(Warn("[WARN] no name in %s, it will not be linked", path.Join(args.Rel, "package.json")))
+	}
+	if len(jsConfig.Visibility.Labels) > 0 {
+		r.SetAttr("visibility", jsConfig.Visibility.Labels)
+	}
+
+	// record the package for npm_link_package rules later
+	if packageName != "" {
+		jsConfig.CollectedPackages[packageName] = fmt.Sprintf("//%s:%s", args.Rel, npmPackageRuleName)
+	}
+
+	return []*rule.Rule{r}, []interface{}{&noImports}
+}
+
+// genNpmLinks generates "npm_link_package" rules in the JS root for the
+// workspace packages collected below it, so that they can be imported like
+// third party packages. In pnpm workspaces, npm_link_all_packages links them
+// instead, from the JS root and from each workspace package.
+func (lang *JS) genNpmLinks(args language.GenerateArgs, isJSRoot bool, jsConfig *JsConfig) ([]*rule.Rule, []interface{}) {
+	generatedRules := make([]*rule.Rule, 0)
+	generatedImports := make([]interface{}, 0)
+
+	if !jsConfig.NpmLinkPackages {
+		return generatedRules, generatedImports
+	}
+
+	if jsConfig.NpmLinkAll {
+		isWorkspacePackage := jsConfig.NpmPackageRoot != nil && args.Rel == jsConfig.NpmPackageRoot.root
+		if isJSRoot || isWorkspacePackage {
+			generatedRules = append(generatedRules, rule.NewRule(getKind(args.Config, "npm_link_all_packages"), npmLinkAllRuleName))
+			generatedImports = append(generatedImports, &noImports)
+		}
+		return generatedRules, generatedImports
+	}
+
+	if !isJSRoot {
+		return generatedRules, generatedImports
+	}
+
+	packageNames := make([]string, 0, len(jsConfig.CollectedPackages))
+	for packageName := range jsConfig.CollectedPackages {
+		packageNames = append(packageNames, packageName)
+	}
+	sort.Strings(packageNames)
+
+	for _, packageName := range packageNames {
+		r := rule.NewRule(getKind(args.Config, "npm_link_package"), "node_modules/"+packageName)
+		r.SetAttr("src", jsConfig.CollectedPackages[packageName])
+
+		generatedRules = append(generatedRules, r)
+		generatedImports = append(generatedImports, &noImports)
+	}
+
+	return generatedRules, generatedImports
+}
+
+// readPackageEntryPoints returns the executables declared by the "bin" field
+// of a package.json file, and its "main" field if withMain is set
+func readPackageEntryPoints(filePath string, withMain bool) ([]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	packageJSON := struct {
+		Main string          `json:"main"`
+		Bin  json.RawMessage `json:"bin"`
+	}{}
+	if err := json.Unmarshal(data, &packageJSON); err != nil {
+		return nil, err
+	}
+
+	entryPoints := []string{}
+	if len(packageJSON.Bin) > 0 {
+		// "bin" is either a single path or a map of command names to paths
+		var single string
+		var commands map[string]string
+		if err := json.Unmarshal(packageJSON.Bin, &single); err == nil {
+			entryPoints = append(entryPoints, single)
+		} else if err := json.Unmarshal(packageJSON.Bin, &commands); err == nil {
+			for _, entryPoint := range commands {
+				entryPoints = append(entryPoints, entryPoint)
+			}
+		} else {
+			return nil, err
+		}
+	}
+	if withMain && packageJSON.Main != "" {
+		entryPoints = append(entryPoints, packageJSON.Main)
+	}
+	sort.Strings(entryPoints)
+	return entryPoints, nil
+}
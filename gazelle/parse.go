@@ -27,7 +27,9 @@ import (
 
 var quotePattern = regexp.MustCompile(`([/][/].*)|(?:[/][*](?:\n|.)*?[*][/])`)
 
-func ParseJS(data []byte) ([]string, int, error) {
+// ParseJS returns the imports of a JS or TS file, the number of tests it
+// contains and the pragmas of its leading docblock, eg. "@jest-environment"
+func ParseJS(data []byte) ([]string, int, map[string]string, error) {
 
 	lastCommentMatchIndex := 0
 	codeBlocks := make([][]int, 0)
@@ -43,14 +45,31 @@ func ParseJS(data []byte) ([]string, int, error) {
 	for _, block := range codeBlocks {
 		blockImports, blockTestCount, err := parseCodeBlock(data[block[0]:block[1]])
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, nil, err
 		}
 		imports = append(imports, blockImports...)
 		jestTestCount += blockTestCount
 	}
 	sort.Strings(imports)
 
-	return imports, jestTestCount, nil
+	return imports, jestTestCount, parsePragmas(data), nil
+}
+
+var docblockPattern = regexp.MustCompile(`^(?:#![^\n]*\n)?\s*/\*\*((?:\n|.)*?)\*/`)
+var pragmaPattern = regexp.MustCompile(`(?m)^[\s*]*@([\w-]+)[ \t]*([^\n]*?)[ \t]*$`)
+
+// parsePragmas returns the pragmas of the docblock at the start of a file,
+// pragmas without a value map to ""
+func parsePragmas(data []byte) map[string]string {
+	pragmas := make(map[string]string)
+	docblock := docblockPattern.FindSubmatch(data)
+	if docblock == nil {
+		return pragmas
+	}
+	for _, match := range pragmaPattern.FindAllSubmatch(docblock[1], -1) {
+		pragmas[string(match[1])] = strings.TrimSuffix(strings.TrimSpace(string(match[2])), "*/")
+	}
+	return pragmas
 }
 
 const (
@@ -68,7 +87,7 @@ func compileJsImportPattern() *regexp.Regexp {
 	importPattern := `^import\s(?:(?:.|\n)+?from )??(?P<import>` + stringLiteralPattern + `)`
 	requirePattern := `^\s*?(?:const .+ = )?require\((?P<require>` + stringLiteralPattern + `)\)`
 	exportPattern := `^export\s(?:(?:.|\n)+?from )??(?P<export>` + stringLiteralPattern + `)`
-	jestMockPattern := `^\s*?(?:const .+ = )?jest.mock\((?P<jestMock>` + stringLiteralPattern + `),`
+	jestMockPattern := `^\s*?(?:const .+ = )?jest.mock\((?P<jestMock>` + stringLiteralPattern + `)[,)]`
 	dynamicImportPattern := `^.*?import\((?P<dynamicImport>` + stringLiteralPattern + `)\)`
 	return regexp.MustCompile(`(?m)` + strings.Join([]string{importPattern, requirePattern, exportPattern, jestMockPattern, dynamicImportPattern}, "|"))
 }
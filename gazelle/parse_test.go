@@ -138,10 +138,17 @@ if (process.ENV.SHOULD_IMPORT) {
 			const foo = import('dynamic_module2.js')`,
 			want: []string{"dynamic_module.js", "dynamic_module2.js"},
 		},
+		{
+			desc: "jest mock",
+			name: "mock.test.js",
+			js: `jest.mock('./api')
+jest.mock('./store', () => ({}))`,
+			want: []string{"./api", "./store"},
+		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
 
-			imports, _, err := ParseJS([]byte(tc.js))
+			imports, _, _, err := ParseJS([]byte(tc.js))
 			if err != nil {
 				t.Error(err)
 				t.FailNow()
@@ -153,3 +160,156 @@ if (process.ENV.SHOULD_IMPORT) {
 		})
 	}
 }
+
+func TestParsePragmas(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		js   string
+		want map[string]string
+	}{
+		{
+			desc: "single line",
+			js:   "/** @jest-environment jsdom */\nimport React from 'react'",
+			want: map[string]string{"jest-environment": "jsdom"},
+		},
+		{
+			desc: "multi line",
+			js: `/**
+ * @jest-environment node
+ * @bazel-tags manual, integration
+ * @flaky
+ */
+test('works', () => {})`,
+			want: map[string]string{"jest-environment": "node", "bazel-tags": "manual, integration", "flaky": ""},
+		},
+		{
+			desc: "after shebang",
+			js:   "#!/usr/bin/env node\n/** @bazel-size large */\n",
+			want: map[string]string{"bazel-size": "large"},
+		},
+		{
+			desc: "not leading",
+			js:   "import x from 'x'\n/** @jest-environment jsdom */\n",
+			want: map[string]string{},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, _, pragmas, err := ParseJS([]byte(tc.js))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pragmas, tc.want) {
+				t.Errorf("got %#v; want %#v", pragmas, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseSFC(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		sfc       string
+		imports   []string
+		styleSrcs []string
+		isTS      bool
+	}{
+		{
+			desc: "vue script and script setup",
+			sfc: `<template><Button /></template>
+<script>
+import { defineComponent } from "vue";
+</script>
+<script setup lang="ts">
+import Button from "./Button.vue";
+// import Unused from "./Unused.vue";
+</script>
+<style scoped src="./card.css"></style>`,
+			imports:   []string{"./Button.vue", "vue"},
+			styleSrcs: []string{"./card.css"},
+			isTS:      true,
+		},
+		{
+			desc: "svelte module context",
+			sfc: `<script context="module">
+  export const prerender = true;
+</script>
+<script>
+  import { onMount } from 'svelte';
+  import Nav from '../nav/Nav.svelte';
+</script>
+<h1>Hello</h1>`,
+			imports:   []string{"../nav/Nav.svelte", "svelte"},
+			styleSrcs: []string{},
+		},
+		{
+			desc:      "template only",
+			sfc:       `<template><p>import x from "x"</p></template>`,
+			imports:   []string{},
+			styleSrcs: []string{},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			imports, styleSrcs, isTS, err := ParseSFC([]byte(tc.sfc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(imports, tc.imports) {
+				t.Errorf("imports: got %#v; want %#v", imports, tc.imports)
+			}
+			if !reflect.DeepEqual(styleSrcs, tc.styleSrcs) {
+				t.Errorf("styleSrcs: got %#v; want %#v", styleSrcs, tc.styleSrcs)
+			}
+			if isTS != tc.isTS {
+				t.Errorf("isTS: got %v; want %v", isTS, tc.isTS)
+			}
+		})
+	}
+}
+
+func TestParseMDX(t *testing.T) {
+	mdx := `import { Meta } from "@storybook/blocks";
+import Button from "./Button";
+
+export const meta = { title: "Button" };
+
+# Button
+
+Text mentioning import x from "not-an-import" mid sentence.
+
+` + "```js\nimport Fenced from \"fenced\";\n```" + `
+
+<Button label="Click" />
+`
+	imports, err := ParseMDX([]byte(mdx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"./Button", "@storybook/blocks"}
+	if !reflect.DeepEqual(imports, want) {
+		t.Errorf("got %#v; want %#v", imports, want)
+	}
+}
+
+func TestParseAstro(t *testing.T) {
+	astro := `---
+import Layout from "../layouts/Layout.astro";
+import { format } from "date-fns";
+
+const title = "Home";
+---
+<Layout title={title}>
+  <p>import x from "not-an-import"</p>
+</Layout>
+<script>
+  import confetti from "canvas-confetti";
+</script>
+`
+	imports, err := ParseAstro([]byte(astro))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"../layouts/Layout.astro", "canvas-confetti", "date-fns"}
+	if !reflect.DeepEqual(imports, want) {
+		t.Errorf("got %#v; want %#v", imports, want)
+	}
+}
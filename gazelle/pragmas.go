@@ -0,0 +1,119 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// testSizes lists the sizes of test rules from smallest to largest
+var testSizes = []string{"small", "medium", "large", "enormous"}
+
+// testFilePragmas are the docblock pragmas of a test file, with the directory
+// of the file relative to its rule
+type testFilePragmas struct {
+	rel     string
+	pragmas map[string]string
+}
+
+// testEnvironmentImport returns the module imported by a @jest-environment
+// pragma, relative to the rule when it is a path, if any
+func testEnvironmentImport(rel string, environment string) string {
+	switch {
+	case environment == "node":
+		// the node environment ships with jest
+		return ""
+	case strings.HasPrefix(environment, ".") && rel != "":
+		return path.Join(rel, environment)
+	case strings.HasPrefix(environment, "."):
+		return environment
+	case strings.HasPrefix(environment, "/"),
+		strings.HasPrefix(environment, "@"),
+		strings.HasPrefix(environment, "jest-environment-"):
+		return environment
+	default:
+		return "jest-environment-" + environment
+	}
+}
+
+// applyTestPragmas translates the docblock pragmas of the test files of a rule
+// into its imports and attributes. When several files share the rule, the
+// rule gets the largest size, the union of the tags and is flaky when any
+// file is.
+func applyTestPragmas(jsConfig *JsConfig, runnerName string, r *rule.Rule, fileImports *imports, files []testFilePragmas) {
+	size := ""
+	tagSet := make(map[string]bool)
+	flaky := false
+
+	for _, file := range files {
+		if environment, ok := file.pragmas["jest-environment"]; ok && environment != "" {
+			if runnerName == "jest" {
+				if imp := testEnvironmentImport(file.rel, environment); imp != "" {
+					fileImports.set[imp] = true
+				}
+			} else if !jsConfig.Quiet {
+				log.Print(Warn("[%s] @jest-environment is ignored by %s", r.Name(), runnerName))
+			}
+		}
+		if fileSize, ok := file.pragmas["bazel-size"]; ok {
+			if indexOf(testSizes, fileSize) < 0 {
+				log.Print(Warn("[%s] unknown @bazel-size %q, expected one of %s", r.Name(), fileSize, strings.Join(testSizes, ", ")))
+			} else if indexOf(testSizes, fileSize) > indexOf(testSizes, size) {
+				size = fileSize
+			}
+		}
+		if tags, ok := file.pragmas["bazel-tags"]; ok {
+			for _, tag := range strings.Split(tags, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					tagSet[tag] = true
+				}
+			}
+		}
+		if _, ok := file.pragmas["flaky"]; ok {
+			flaky = true
+		}
+	}
+
+	if size != "" {
+		r.SetAttr("size", size)
+	}
+	if len(tagSet) > 0 {
+		tags := make([]string, 0, len(tagSet))
+		for tag := range tagSet {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		r.SetAttr("tags", tags)
+	}
+	if flaky {
+		r.SetAttr("flaky", true)
+	}
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -21,7 +21,6 @@ import (
 	"log"
 	"os"
 	"path"
-	"path/filepath"
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
@@ -89,7 +88,21 @@ func (lang *JS) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resolve.
 	jsConfigs := c.Exts[languageName].(JsConfigs)
 	jsConfig := jsConfigs[f.Pkg]
 
+	if r.Kind() == getKind(c, "npm_package") || r.Kind() == getKind(c, "npm_link_package") || r.Kind() == getKind(c, "npm_link_all_packages") {
+		// packages are imported through their linked node_modules target
+		return nil
+	}
+	if r.Kind() == getKind(c, "next_build") || r.Kind() == getKind(c, "next_dev") {
+		// next apps are built and served, never imported
+		return nil
+	}
+
+	lang.recordTestOnly(r, f)
+
 	srcs := r.AttrStrings("srcs")
+	if r.Kind() == getKind(c, "ts_config") {
+		srcs = append(srcs, r.AttrString("src"))
+	}
 
 	importSpecs := make([]resolve.ImportSpec, 0)
 
@@ -119,22 +132,26 @@ func (lang *JS) Imports(c *config.Config, r *rule.Rule, f *rule.File) []resolve.
 		})
 	}
 
+	// manual mocks are indexed by the module they mock
+	importSpecs = append(importSpecs, lang.mockImportSpecs(jsConfig, f.Pkg, srcs)...)
+
 	// Any subfolders could be used to depend on this rule
 	folderImports := jsConfig.CollectAll && (r.Kind() == getKind(c, "ts_project") || r.Kind() == getKind(c, "js_library"))
 	if folderImports {
-		base := filepath.Dir(f.Path)
 		subDirectories := make(map[string]bool)
 		for _, src := range srcs {
-			dir := filepath.Dir(src)
+			dir := path.Dir(src)
 			subDirectories[dir] = true
 		}
 		for subDirectory, _ := range subDirectories {
-			root := strings.TrimSuffix(base, f.Pkg)
-			relPath := strings.TrimPrefix(subDirectory, root)
-			path := fmt.Sprintf("%s/%s", f.Pkg, relPath)
+			dirPath := path.Join(f.Pkg, subDirectory)
+			if dirPath == f.Pkg && isBarrel {
+				// already indexed as a module
+				continue
+			}
 			importSpecs = append(importSpecs, resolve.ImportSpec{
 				Lang: lang.Name(),
-				Imp:  path,
+				Imp:  dirPath,
 			})
 		}
 	}
@@ -181,6 +198,9 @@ func (lang *JS) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Remote
 	imports := _imports.(*imports)
 	depSet := make(map[string]bool)
 	dataSet := make(map[string]bool)
+	runnerName, runner, isTest := testRunnerForKind(c, r.Kind())
+	isJestTest := isTest && runnerName == "jest"
+	mockedImports := make(map[string]bool)
 	for name := range imports.set {
 
 		// is it a package.json import?
@@ -189,6 +209,11 @@ func (lang *JS) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Remote
 			continue
 		}
 
+		// apply the moduleNameMapper of the jest config
+		if isJestTest && jsConfig.JestConfig != nil {
+			name = jsConfig.JestConfig.mapImport(from.Pkg, name)
+		}
+
 		// fix aliases
 		match := jsConfig.ImportAliasPattern.FindStringSubmatch(name)
 		if len(match) > 0 {
@@ -206,6 +231,10 @@ func (lang *JS) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Remote
 
 		// is it an npm dependency?
 		isNpm, npmLabel, devDep := lang.isNpmDependency(name, jsConfig)
+		if isTest {
+			// the module may have a manual mock
+			mockedImports[name] = isNpm
+		}
 		if isNpm {
 
 			s := strings.Split(name, "/")
@@ -265,28 +294,49 @@ func (lang *JS) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Remote
 		lang.resolveWalkParents(name, depSet, dataSet, c, ix, rc, r, from)
 	}
 
-	// Add in additional jest dependencies
-	if r.Kind() == getKind(c, "jest_test") {
-		for name, npmLabel := range jsConfig.NpmDependencies.DevDependencies {
-			if name == "jest-cli" || name == "jest-junit" {
-				continue
-			}
-			if strings.HasPrefix(name, "@types/jest") {
-				depSet[fmt.Sprintf("%s%s", npmLabel, name)] = true
-			}
-			if strings.HasPrefix(name, "jest") {
-				depSet[fmt.Sprintf("%s%s", npmLabel, name)] = true
-				dataSet[fmt.Sprintf("%s%s", npmLabel, name)] = true
-			}
+	// Add manual mocks of the imported modules
+	if isTest {
+		lang.resolveMocks(mockedImports, depSet, c, ix, from)
+	}
+
+	// Add dependencies known at generation time
+	if explicitDeps, ok := r.PrivateAttr(explicitDepsKey).([]string); ok {
+		for _, dep := range explicitDeps {
+			depSet[dep] = true
 		}
+	}
+	if explicitData, ok := r.PrivateAttr(explicitDataKey).([]string); ok {
+		for _, d := range explicitData {
+			dataSet[d] = true
+		}
+	}
 
-		packageLocation := jsConfig.JSRoot
-		if packageLocation == "." {
-			packageLocation = ""
+	// Add in the dependencies of the test runner
+	if isTest {
+		runner.addImplicitDeps(jsConfig, runnerName, depSet, dataSet)
+	}
+
+	if r.Kind() == getKind(c, "ts_config") {
+		// ts_config has no data attribute, extended configs are deps
+		for d := range dataSet {
+			depSet[d] = true
 		}
-		dataSet[fmt.Sprintf("//%s:package_json", packageLocation)] = true
+		dataSet = make(map[string]bool)
 	}
 
+	if jsConfig.ReportCycles {
+		for dep := range depSet {
+			if lbl, err := label.Parse(dep); err == nil {
+				lbl = lbl.Abs(from.Repo, from.Pkg)
+				if lbl.Pkg != from.Pkg {
+					lang.recordDependency(from, lbl)
+				}
+			}
+		}
+	}
+
+	lang.reportTestOnlyDeps(c, jsConfig, r, from, depSet)
+
 	deps := []string{}
 	for dep := range depSet {
 		deps = append(deps, dep)
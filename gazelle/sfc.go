@@ -0,0 +1,208 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// sfcKinds maps the extension of single-file components, and of MDX and Astro
+// files, to the kind of the rules compiling them
+var sfcKinds = map[string]string{
+	".vue":    "vue_component",
+	".svelte": "svelte_component",
+	".astro":  "astro_component",
+	".mdx":    "mdx_library",
+}
+
+// sfcRuntimes are the modules imported by every compiled component
+var sfcRuntimes = map[string]string{
+	".vue":    "vue",
+	".svelte": "svelte",
+	".astro":  "astro",
+}
+
+var sfcExtensionsPattern = regexp.MustCompile(`\.(vue|svelte|astro|mdx)$`)
+
+var scriptBlockPattern = regexp.MustCompile(`(?is)<script\b([^>]*)>(.*?)</script\s*>`)
+var scriptLangPattern = regexp.MustCompile(`(?i)\blang\s*=\s*["'](ts|typescript)["']`)
+var styleSrcPattern = regexp.MustCompile(`(?is)<style\b[^>]*?\bsrc\s*=\s*["']([^"']+)["']`)
+
+// indentPattern matches the indentation of script blocks, which the import
+// parser expects at the start of lines
+var indentPattern = regexp.MustCompile(`(?m)^[ \t]+`)
+
+func isSFCFile(baseName string) bool {
+	return sfcExtensionsPattern.MatchString(baseName)
+}
+
+// ParseSFC returns the imports of the <script> blocks of a Vue or Svelte
+// component, the files referenced by its <style src> blocks and whether any
+// of its scripts is TypeScript
+func ParseSFC(data []byte) ([]string, []string, bool, error) {
+	imports := make([]string, 0)
+	isTS := false
+	for _, match := range scriptBlockPattern.FindAllSubmatch(data, -1) {
+		if scriptLangPattern.Match(match[1]) {
+			isTS = true
+		}
+		scriptImports, _, _, err := ParseJS(indentPattern.ReplaceAll(match[2], nil))
+		if err != nil {
+			return nil, nil, false, err
+		}
+		imports = append(imports, scriptImports...)
+	}
+	sort.Strings(imports)
+
+	styleSrcs := make([]string, 0)
+	for _, match := range styleSrcPattern.FindAllSubmatch(data, -1) {
+		styleSrcs = append(styleSrcs, string(match[1]))
+	}
+	return imports, styleSrcs, isTS, nil
+}
+
+// sfcFile is a parsed single-file component
+type sfcFile struct {
+	imports   *imports
+	styleData []string
+	isTS      bool
+}
+
+// readSFCAndParse parses the component at src, relative to the package at
+// args, style files of the package become data and others are imported
+func readSFCAndParse(args language.GenerateArgs, src string) sfcFile {
+	filePath := path.Join(args.Dir, src)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Fatalf(Err("Error reading %s: %v", filePath, err))
+	}
+	var sfcImports, styleSrcs []string
+	var isTS bool
+	switch path.Ext(src) {
+	case ".mdx":
+		sfcImports, err = ParseMDX(data)
+	case ".astro":
+		sfcImports, err = ParseAstro(data)
+	default:
+		sfcImports, styleSrcs, isTS, err = ParseSFC(data)
+	}
+	if err != nil {
+		log.Fatalf(Err("Error parsing %s: %v", filePath, err))
+	}
+
+	// the compiled component always imports the runtime of its framework
+	fileImports := imports{
+		set: make(map[string]bool),
+	}
+	if runtime, ok := sfcRuntimes[path.Ext(src)]; ok {
+		fileImports.set[runtime] = true
+	}
+	rel := path.Dir(src)
+	for _, imp := range sfcImports {
+		if strings.HasPrefix(imp, ".") {
+			imp = path.Join(rel, imp)
+		}
+		fileImports.set[imp] = true
+	}
+
+	styleData := []string{}
+	for _, styleSrc := range styleSrcs {
+		styleSrc = path.Join(rel, styleSrc)
+		if strings.HasPrefix(styleSrc, "../") {
+			// style files of other packages come from their web_assets rules
+			fileImports.set[styleSrc] = true
+			continue
+		}
+		styleData = append(styleData, styleSrc)
+	}
+	return sfcFile{imports: &fileImports, styleData: styleData, isTS: isTS}
+}
+
+// hasSFCFiles reports whether a package has files compiled by the rules of a
+// kind
+func (lang *JS) hasSFCFiles(args language.GenerateArgs, jsConfig *JsConfig, kind string) bool {
+	for _, baseName := range lang.gatherFiles(args, jsConfig) {
+		if isSFCFile(baseName) && getKind(args.Config, sfcKinds[path.Ext(baseName)]) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// genSFCs generates a rule for each Vue, Svelte, Astro or MDX file of a
+// package, named like "Button_vue", or one per kind when all sources are
+// collected
+func (lang *JS) genSFCs(args language.GenerateArgs, jsConfig *JsConfig, pkgName string) ([]*rule.Rule, []interface{}) {
+	groups := make(map[string][]string)
+	for _, baseName := range lang.gatherFiles(args, jsConfig) {
+		if !isSFCFile(baseName) {
+			continue
+		}
+		// names end with the extension, so they do not collide with the
+		// rules of sources named alike, eg. "Button.tsx"
+		ext := strings.TrimPrefix(path.Ext(baseName), ".")
+		name := fmt.Sprintf("%s_%s", strings.TrimSuffix(baseName, path.Ext(baseName)), ext)
+		if jsConfig.CollectAll {
+			name = fmt.Sprintf("%s_%s", pkgName, ext)
+		}
+		groups[name] = append(groups[name], baseName)
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	generatedRules := make([]*rule.Rule, 0)
+	generatedImports := make([]interface{}, 0)
+	for _, name := range names {
+		srcs := groups[name]
+		sort.Strings(srcs)
+
+		isTS := false
+		allImports := []imports{}
+		styleData := []string{}
+		for _, src := range srcs {
+			sfc := readSFCAndParse(args, src)
+			isTS = isTS || sfc.isTS
+			allImports = append(allImports, *sfc.imports)
+			styleData = append(styleData, sfc.styleData...)
+		}
+
+		r := rule.NewRule(getKind(args.Config, sfcKinds[path.Ext(srcs[0])]), name)
+		r.SetAttr("srcs", srcs)
+		if isTS {
+			r.SetAttr("lang", "ts")
+		}
+		addExplicitData(r, styleData)
+		if len(jsConfig.Visibility.Labels) > 0 {
+			r.SetAttr("visibility", jsConfig.Visibility.Labels)
+		}
+		generatedRules = append(generatedRules, r)
+		generatedImports = append(generatedImports, flattenImports(allImports))
+	}
+	return generatedRules, generatedImports
+}
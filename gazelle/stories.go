@@ -0,0 +1,105 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// storiesRuleSuffix ends the name of the library of the stories of a package,
+// like "button_stories", so that it does not collide with a "stories" module
+const storiesRuleSuffix = "_stories"
+
+// storybookRuleName is the name of the rule aggregating all stories at the JS
+// root
+const storybookRuleName = "storybook"
+
+var storiesExtensionsPattern = regexp.MustCompile(`\.stories\.(ts|tsx|js|jsx)$`)
+
+func isStoriesFile(baseName string) bool {
+	return storiesExtensionsPattern.MatchString(baseName)
+}
+
+// isStoriesRule reports whether a rule is a library of stories
+func isStoriesRule(r *rule.Rule) bool {
+	srcs := r.AttrStrings("srcs")
+	for _, src := range srcs {
+		if !isStoriesFile(path.Base(src)) {
+			return false
+		}
+	}
+	return len(srcs) > 0
+}
+
+// genStories generates a library of the stories of a package, apart from its
+// production rules, and records it for the storybook rule. It is a ts_project
+// when any of the stories is TypeScript
+func (lang *JS) genStories(args language.GenerateArgs, jsConfig *JsConfig, pkgName string, storySources []string) ([]*rule.Rule, []interface{}) {
+	if len(storySources) == 0 {
+		return nil, nil
+	}
+
+	kind := "js_library"
+	var allImports []imports
+	for _, src := range storySources {
+		if tsExtensionsPattern.MatchString(src) {
+			kind = "ts_project"
+		}
+		imps, _, _ := readFileAndParse(path.Join(args.Dir, src), path.Dir(src))
+		allImports = append(allImports, *imps)
+	}
+
+	// the stories of the root package are just "stories"
+	name := strings.TrimPrefix(pkgName+storiesRuleSuffix, "_")
+	r := rule.NewRule(getKind(args.Config, kind), name)
+	r.SetAttr("srcs", storySources)
+	if len(jsConfig.Visibility.Labels) > 0 {
+		r.SetAttr("visibility", jsConfig.Visibility.Labels)
+	}
+
+	// record the stories for the storybook rule
+	jsConfig.CollectedStories[fmt.Sprintf("//%s:%s", args.Rel, name)] = true
+
+	return []*rule.Rule{r}, []interface{}{flattenImports(allImports)}
+}
+
+// genStorybook generates the storybook rule of all the stories below the JS
+// root
+func (lang *JS) genStorybook(args language.GenerateArgs, isJSRoot bool, jsConfig *JsConfig) ([]*rule.Rule, []interface{}) {
+	if !isJSRoot || len(jsConfig.CollectedStories) == 0 {
+		return nil, nil
+	}
+	stories := make([]string, 0, len(jsConfig.CollectedStories))
+	for lbl := range jsConfig.CollectedStories {
+		stories = append(stories, lbl)
+	}
+	sort.Strings(stories)
+
+	r := rule.NewRule(getKind(args.Config, "storybook"), storybookRuleName)
+	r.SetAttr("stories", stories)
+	if len(jsConfig.Visibility.Labels) > 0 {
+		r.SetAttr("visibility", jsConfig.Visibility.Labels)
+	}
+	return []*rule.Rule{r}, []interface{}{&noImports}
+}
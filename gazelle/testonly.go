@@ -0,0 +1,193 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// defaultTestOnlyPackages are npm packages only used by tests, package scopes
+// end with "/"
+var defaultTestOnlyPackages = []string{
+	"@jest/",
+	"@playwright/test",
+	"@testing-library/",
+	"@vitest/",
+	"chai",
+	"cypress",
+	"enzyme",
+	"jest",
+	"mocha",
+	"msw",
+	"nock",
+	"sinon",
+	"supertest",
+	"vitest",
+}
+
+// test helpers named like "test-utils.ts", "testHelpers.js" or "setupTests.ts"
+var testHelperPattern = regexp.MustCompile(`(?i)^(test[-_]?(utils?|helpers?|setup)|setup[-_]?tests?)$`)
+
+// directories holding test helpers
+var testHelperDirs = map[string]bool{
+	"testing":        true,
+	"test-utils":     true,
+	"test_utils":     true,
+	"testutils":      true,
+	"__test_utils__": true,
+}
+
+// isTestHelperFile reports whether a source file is a test helper by its name
+// or directory, given its path in the repository
+func isTestHelperFile(file string) bool {
+	if testHelperPattern.MatchString(trimExt(path.Base(file))) {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(file), "/") {
+		if testHelperDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestOnlyPackage reports whether an npm package, imported by name or by a
+// path inside it, is only used by tests and is a devDependency
+func (jsConfig *JsConfig) isTestOnlyPackage(imp string) bool {
+	for _, pkg := range jsConfig.TestOnlyPackages {
+		var name string
+		if strings.HasSuffix(pkg, "/") {
+			if !strings.HasPrefix(imp, pkg) {
+				continue
+			}
+			name = pkg + strings.SplitN(strings.TrimPrefix(imp, pkg), "/", 2)[0]
+		} else if imp == pkg || strings.HasPrefix(imp, pkg+"/") {
+			name = pkg
+		} else {
+			continue
+		}
+		if _, ok := jsConfig.NpmDependencies.DevDependencies[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// setTestOnlyAttrs marks library rules as testonly when all their sources are
+// test helpers, or when they import npm packages only used by tests
+func (lang *JS) setTestOnlyAttrs(args language.GenerateArgs, jsConfig *JsConfig, generatedRules []*rule.Rule, generatedImports []interface{}) {
+	if !jsConfig.InferTestOnly {
+		return
+	}
+	for i, r := range generatedRules {
+		if r.Kind() != getKind(args.Config, "ts_project") && r.Kind() != getKind(args.Config, "js_library") {
+			continue
+		}
+		if r.Name() == "package_json" || isTestOnly(r) {
+			continue
+		}
+		srcs := r.AttrStrings("srcs")
+		helpers := len(srcs) > 0
+		for _, src := range srcs {
+			if !isTestHelperFile(path.Join(args.Rel, src)) {
+				helpers = false
+				break
+			}
+		}
+		testImports := false
+		if imps, ok := generatedImports[i].(*imports); ok {
+			for imp := range imps.set {
+				if jsConfig.isTestOnlyPackage(imp) {
+					testImports = true
+					break
+				}
+			}
+		}
+		if helpers || testImports {
+			r.SetAttr("testonly", true)
+		}
+	}
+}
+
+// isTestOnly reports whether a rule sets testonly = True
+func isTestOnly(r *rule.Rule) bool {
+	switch expr := r.Attr("testonly").(type) {
+	case *bzl.Ident:
+		// read from a build file
+		return expr.Name == "True"
+	case *bzl.LiteralExpr:
+		// set by SetAttr
+		return expr.Token == "True"
+	}
+	return false
+}
+
+// recordTestOnly remembers the testonly rules of a package, whether generated
+// or hand written
+func (lang *JS) recordTestOnly(r *rule.Rule, f *rule.File) {
+	if !isTestOnly(r) {
+		return
+	}
+	if lang.testOnlyRules == nil {
+		lang.testOnlyRules = make(map[string]bool)
+	}
+	lang.testOnlyRules[label.New("", f.Pkg, r.Name()).String()] = true
+}
+
+// reportTestOnlyDeps handles rules that are not tests nor testonly and depend
+// on a testonly rule: generated libraries are marked testonly too when
+// js_testonly_inference is set, and other rules are reported
+func (lang *JS) reportTestOnlyDeps(c *config.Config, jsConfig *JsConfig, r *rule.Rule, from label.Label, depSet map[string]bool) {
+	if isTestOnly(r) {
+		return
+	}
+	if _, _, isTest := testRunnerForKind(c, r.Kind()); isTest {
+		return
+	}
+	for dep := range depSet {
+		lbl, err := label.Parse(dep)
+		if err != nil {
+			continue
+		}
+		lbl = lbl.Abs(from.Repo, from.Pkg)
+		if lbl.Repo != from.Repo {
+			continue
+		}
+		lbl.Repo = ""
+		if !lang.testOnlyRules[lbl.String()] {
+			continue
+		}
+		if jsConfig.InferTestOnly && (r.Kind() == getKind(c, "ts_project") || r.Kind() == getKind(c, "js_library")) {
+			r.SetAttr("testonly", true)
+			// rules resolved after this one may depend on it
+			lang.testOnlyRules[label.New("", from.Pkg, from.Name).String()] = true
+			return
+		}
+		if !jsConfig.Quiet {
+			log.Print(Warn("[WARN] %s depends on testonly %s, mark it testonly or only depend on it from tests", from.String(), lbl.String()))
+		}
+	}
+}
@@ -0,0 +1,386 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+// testRunner describes the test rules generated for a test framework
+type testRunner struct {
+	// kind of the generated test rules
+	kind string
+	// attribute holding the config file of the runner, if any
+	configAttr string
+	// whether a missing config file is worth a warning
+	configRequired bool
+	// config files discovered in each directory, in order of preference
+	configFiles []string
+	// package.json key holding the config, if any
+	packageConfigKey string
+	// whether tests are split with shard_count by counted tests
+	sharded bool
+	// attribute generating a target to update snapshot files, if any
+	snapshotsAttr string
+	// npm packages added to the deps of each test, unless overridden by the
+	// "js_test_implicit_deps" directive
+	implicitDeps []string
+	// npm packages provided by the test rule itself, never added
+	excludedDeps []string
+}
+
+var testRunners = map[string]*testRunner{
+	"jest": {
+		kind:             "jest_test",
+		configAttr:       "config",
+		configRequired:   true,
+		configFiles:      []string{"jest.config.js", "jest.config.ts", "jest.config.mjs", "jest.config.cjs", "jest.config.json"},
+		packageConfigKey: "jest",
+		sharded:          true,
+		snapshotsAttr:    "snapshots",
+		implicitDeps:     []string{"@types/jest"},
+		excludedDeps:     []string{"jest-cli", "jest-junit"},
+	},
+	"vitest": {
+		kind:         "vitest_test",
+		configAttr:   "config",
+		configFiles:  []string{"vitest.config.ts", "vitest.config.js", "vitest.config.mts", "vitest.config.mjs"},
+		implicitDeps: []string{"vitest"},
+	},
+	"mocha": {
+		kind:             "mocha_test",
+		configAttr:       "config",
+		configFiles:      []string{".mocharc.js", ".mocharc.cjs", ".mocharc.json", ".mocharc.yml", ".mocharc.yaml"},
+		packageConfigKey: "mocha",
+		implicitDeps:     []string{"mocha", "@types/mocha", "chai", "@types/chai"},
+	},
+	"node": {
+		kind:         "node_test",
+		implicitDeps: []string{"@types/node", "tsx"},
+	},
+}
+
+// test runners detected from npm dependencies in order, when jest is neither a
+// dependency nor configured
+var detectedTestRunners = []string{"vitest", "mocha"}
+
+const defaultTestRunner = "jest"
+
+const snapshotDirName = "__snapshots__"
+
+func testRunnerNames() []string {
+	names := make([]string, 0, len(testRunners))
+	for name := range testRunners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// testRunnerName returns the test runner set by the "js_test_runner"
+// directive, or jest when it is a dependency or has a config, or else the
+// first one found in the npm dependencies
+func (jsConfig *JsConfig) testRunnerName() string {
+	if jsConfig.TestRunner != "" {
+		return jsConfig.TestRunner
+	}
+	if jsConfig.TestConfigs[defaultTestRunner] != "" || jsConfig.hasNpmDependency(defaultTestRunner) {
+		return defaultTestRunner
+	}
+	for _, name := range detectedTestRunners {
+		if jsConfig.hasNpmDependency(name) {
+			return name
+		}
+	}
+	return defaultTestRunner
+}
+
+func (jsConfig *JsConfig) hasNpmDependency(name string) bool {
+	if _, ok := jsConfig.NpmDependencies.DevDependencies[name]; ok {
+		return true
+	}
+	_, ok := jsConfig.NpmDependencies.Dependencies[name]
+	return ok
+}
+
+// lookupTestRunner returns a unit or end-to-end test runner by name
+func lookupTestRunner(name string) (*testRunner, bool) {
+	if runner, ok := testRunners[name]; ok {
+		return runner, true
+	}
+	runner, ok := e2eRunners[name]
+	return runner, ok
+}
+
+// testRunnerForKind returns the name of the test runner generating rules of a
+// kind, and the runner
+func testRunnerForKind(c *config.Config, kind string) (string, *testRunner, bool) {
+	for _, name := range testRunnerNames() {
+		if getKind(c, testRunners[name].kind) == kind {
+			return name, testRunners[name], true
+		}
+	}
+	for name, runner := range e2eRunners {
+		if getKind(c, runner.kind) == kind {
+			return name, runner, true
+		}
+	}
+	return "", nil, false
+}
+
+func (runner *testRunner) addTestAttributes(args language.GenerateArgs, jsConfig *JsConfig, runnerName string, ruleName string, r *rule.Rule, testCount int) {
+	if runner.configAttr != "" {
+		testConfig := jsConfig.TestConfigs[runnerName]
+		if testConfig == "" && runner.configRequired && !jsConfig.Quiet {
+			log.Print(Warn("[%s/%s] no config for %s, use gazelle:js_%s_config directive", args.Rel, ruleName, runner.kind, runnerName))
+		}
+		if testConfig != "" || runner.configRequired {
+			r.SetAttr(runner.configAttr, testConfig)
+		}
+	}
+	// shard by historical durations, or else by counted tests
+	if runner.sharded && !jsConfig.addDurationAttributes(args.Rel, r) && jsConfig.JestTestsPerShard > 0 {
+		shardCount := int(math.Ceil(float64(testCount) / float64(jsConfig.JestTestsPerShard)))
+		if shardCount > 1 {
+			r.SetAttr("shard_count", shardCount)
+		}
+	}
+	if snapshots := findSnapshots(args.Dir, r.AttrStrings("srcs")); len(snapshots) > 0 {
+		if runner.snapshotsAttr != "" && jsConfig.UpdateSnapshots {
+			// the rule collects the snapshot files itself
+			r.SetAttr(runner.snapshotsAttr, true)
+		} else {
+			addExplicitData(r, snapshots)
+		}
+	}
+	addExplicitData(r, jsConfig.fixtureData(args.Config.RepoRoot, args.Rel, r.AttrStrings("srcs")))
+	// the size from historical durations wins over the default
+	if jsConfig.JestSize != "" && r.Attr("size") == nil {
+		r.SetAttr("size", jsConfig.JestSize)
+	}
+	if len(jsConfig.Visibility.Labels) > 0 {
+		r.SetAttr("visibility", jsConfig.Visibility.Labels)
+	}
+}
+
+// testConfigRuleName is the name of the rule generated for the config file
+// of a test runner, eg. "jest_config"
+func testConfigRuleName(runnerName string) string {
+	return runnerName + "_config"
+}
+
+// findConfigFile returns the config file of the test runner in a directory
+func (runner *testRunner) findConfigFile(dir string) (string, bool) {
+	for _, configFile := range runner.configFiles {
+		if fileInfo, err := os.Stat(path.Join(dir, configFile)); err == nil && !fileInfo.IsDir() {
+			return configFile, true
+		}
+	}
+	return "", false
+}
+
+func (runner *testRunner) isConfigFile(baseName string) bool {
+	for _, configFile := range runner.configFiles {
+		if baseName == configFile {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestConfigFile reports whether a file is the config of a test runner whose
+// config is discovered
+func (jsConfig *JsConfig) isTestConfigFile(baseName string) bool {
+	for _, runnerName := range jsConfig.discoveredTestRunners() {
+		if runner, _ := lookupTestRunner(runnerName); runner.isConfigFile(baseName) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPackageConfig reports whether the package.json file in a directory
+// holds the config of the test runner
+func (runner *testRunner) hasPackageConfig(dir string) bool {
+	if runner.packageConfigKey == "" {
+		return false
+	}
+	data, err := os.ReadFile(path.Join(dir, "package.json"))
+	if err != nil {
+		return false
+	}
+	packageJSON := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &packageJSON); err != nil {
+		return false
+	}
+	_, ok := packageJSON[runner.packageConfigKey]
+	return ok
+}
+
+// discoveredTestRunners returns the names of the test runners whose config is
+// discovered in this directory
+func (jsConfig *JsConfig) discoveredTestRunners() []string {
+	if !jsConfig.FindTestConfigs {
+		return nil
+	}
+	if jsConfig.E2ERunner == "" {
+		return []string{jsConfig.testRunnerName()}
+	}
+	return []string{jsConfig.testRunnerName(), jsConfig.E2ERunner}
+}
+
+// discoverTestConfigs sets the config of the test runners to the nearest
+// config file, or package.json holding their config, unless set by a
+// directive in this directory. A rule of the build file with the config file
+// in its srcs is used as is.
+func (jsConfig *JsConfig) discoverTestConfigs(repoRoot string, rel string, f *rule.File, configured map[string]bool) {
+	dir := path.Join(repoRoot, rel)
+	for _, runnerName := range jsConfig.discoveredTestRunners() {
+		if configured[runnerName] {
+			continue
+		}
+		runner, _ := lookupTestRunner(runnerName)
+		if configFile, ok := runner.findConfigFile(dir); ok {
+			ruleName := testConfigRuleName(runnerName)
+			if existingRule := findRuleWithSrc(f, configFile); existingRule != nil {
+				ruleName = existingRule.Name()
+			}
+			jsConfig.TestConfigs[runnerName] = label.New("", rel, ruleName).String()
+		} else if runner.hasPackageConfig(dir) {
+			jsConfig.TestConfigs[runnerName] = label.New("", rel, "package_json").String()
+		}
+	}
+}
+
+// genTestConfigs generates a rule for each test runner config file found in
+// this directory
+func (lang *JS) genTestConfigs(args language.GenerateArgs, jsConfig *JsConfig) ([]*rule.Rule, []interface{}) {
+	generatedRules := make([]*rule.Rule, 0)
+	generatedImports := make([]interface{}, 0)
+
+	for _, runnerName := range jsConfig.discoveredTestRunners() {
+		runner, _ := lookupTestRunner(runnerName)
+		configFile, ok := runner.findConfigFile(args.Dir)
+		if !ok {
+			continue
+		}
+		// the rule of the config, which may be an existing rule or set by a
+		// directive
+		configLabel, err := label.Parse(jsConfig.TestConfigs[runnerName])
+		if err != nil || configLabel.Pkg != args.Rel || configLabel.Name == "package_json" {
+			continue
+		}
+		kind := getKind(args.Config, "js_library")
+		if existingRule := findRuleWithSrc(args.File, configFile); existingRule != nil && existingRule.Name() == configLabel.Name {
+			kind = existingRule.Kind()
+		}
+
+		r := rule.NewRule(kind, configLabel.Name)
+		r.SetAttr("srcs", []string{configFile})
+		if len(jsConfig.Visibility.Labels) > 0 {
+			r.SetAttr("visibility", jsConfig.Visibility.Labels)
+		}
+
+		// config files may import presets and plugins
+		configImports := &noImports
+		switch path.Ext(configFile) {
+		case ".json", ".yml", ".yaml":
+		default:
+			configImports, _, _ = readFileAndParse(path.Join(args.Dir, configFile), "")
+		}
+
+		generatedRules = append(generatedRules, r)
+		generatedImports = append(generatedImports, configImports)
+	}
+
+	return generatedRules, generatedImports
+}
+
+// findRuleWithSrc returns the rule of a build file with a source file in its
+// srcs, if any
+func findRuleWithSrc(f *rule.File, src string) *rule.Rule {
+	if f == nil {
+		return nil
+	}
+	for _, r := range f.Rules {
+		for _, ruleSrc := range r.AttrStrings("srcs") {
+			if ruleSrc == src || ruleSrc == ":"+src {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// findSnapshots returns the snapshot files written by test sources, which are
+// kept in a "__snapshots__" directory next to each test
+func findSnapshots(dir string, srcs []string) []string {
+	snapshots := []string{}
+	for _, src := range srcs {
+		snapshot := path.Join(path.Dir(src), snapshotDirName, path.Base(src)+".snap")
+		if fileInfo, err := os.Stat(path.Join(dir, snapshot)); err == nil && !fileInfo.IsDir() {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots
+}
+
+// addImplicitDeps adds the npm packages used by the test runner, that the
+// package.json depends on, to the deps of a test rule, and the package.json to
+// its data
+func (runner *testRunner) addImplicitDeps(jsConfig *JsConfig, runnerName string, depSet map[string]bool, dataSet map[string]bool) {
+	implicitDeps, ok := jsConfig.TestImplicitDeps[runnerName]
+	if !ok {
+		implicitDeps = runner.implicitDeps
+	}
+	for _, name := range implicitDeps {
+		if isExcludedDep(runner.excludedDeps, name) {
+			continue
+		}
+		if npmLabel, ok := jsConfig.NpmDependencies.DevDependencies[name]; ok {
+			depSet[fmt.Sprintf("%s%s", npmLabel, name)] = true
+		} else if npmLabel, ok := jsConfig.NpmDependencies.Dependencies[name]; ok {
+			depSet[fmt.Sprintf("%s%s", npmLabel, name)] = true
+		}
+	}
+
+	packageLocation := jsConfig.JSRoot
+	if packageLocation == "." {
+		packageLocation = ""
+	}
+	dataSet[fmt.Sprintf("//%s:package_json", packageLocation)] = true
+}
+
+func isExcludedDep(excludedDeps []string, name string) bool {
+	for _, excluded := range excludedDeps {
+		if name == excluded {
+			return true
+		}
+	}
+	return false
+}
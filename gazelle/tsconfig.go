@@ -0,0 +1,266 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const tsConfigFileName = "tsconfig.json"
+
+var tsConfigFilePattern = regexp.MustCompile(`^tsconfig(\.[^/]+)?\.json$`)
+
+func isTSConfigFile(baseName string) bool {
+	return tsConfigFilePattern.MatchString(baseName)
+}
+
+// tsConfigRuleName returns the name of the ts_config rule generated for a
+// tsconfig file, eg. "tsconfig.base.json" -> "tsconfig_base"
+func tsConfigRuleName(baseName string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(baseName, ".json"), ".", "_")
+}
+
+// tsConfigLabel returns the label of the ts_config rule for a tsconfig file
+// in the package rel, if that file exists
+func tsConfigLabel(repoRoot string, rel string, baseName string) (label.Label, bool) {
+	fileInfo, err := os.Stat(path.Join(repoRoot, rel, baseName))
+	if err != nil || fileInfo.IsDir() {
+		return label.NoLabel, false
+	}
+	return label.New("", rel, tsConfigRuleName(baseName)), true
+}
+
+// tsConfigJSON contains the parts of a tsconfig.json file used to generate
+// rules.
+type tsConfigJSON struct {
+	Extends         tsConfigExtends `json:"extends"`
+	Files           []string        `json:"files"`
+	Include         []string        `json:"include"`
+	Exclude         []string        `json:"exclude"`
+	CompilerOptions struct {
+		Composite bool `json:"composite"`
+	} `json:"compilerOptions"`
+	References []struct {
+		Path string `json:"path"`
+	} `json:"references"`
+}
+
+// tsConfigExtends may be a single path or a list of paths since TS 5.0
+type tsConfigExtends []string
+
+func (e *tsConfigExtends) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*e = tsConfigExtends{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*e = list
+	return nil
+}
+
+func readTSConfig(filePath string) (*tsConfigJSON, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	tsConfig := &tsConfigJSON{}
+	if err := json.Unmarshal(stripJSONC(data), tsConfig); err != nil {
+		return nil, err
+	}
+	return tsConfig, nil
+}
+
+// stripJSONC removes comments and trailing commas from JSON with comments,
+// as accepted by tsc, so that it can be parsed as regular JSON.
+func stripJSONC(data []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out.WriteByte(data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out.WriteByte('\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == ',':
+			// drop trailing commas before a closing bracket
+			j := i + 1
+			for j < len(data) && (data[j] == ' ' || data[j] == '\t' || data[j] == '\n' || data[j] == '\r') {
+				j++
+			}
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				continue
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
+
+// includes reports whether a source file, relative to the directory of the
+// tsconfig file, is part of the project according to "files", "include" and
+// "exclude".
+func (tsConfig *tsConfigJSON) includes(relPath string) bool {
+	for _, file := range tsConfig.Files {
+		if path.Clean(file) == relPath {
+			return true
+		}
+	}
+
+	include := tsConfig.Include
+	if include == nil && tsConfig.Files == nil {
+		include = []string{"**/*"}
+	}
+	if !matchTSConfigPatterns(include, relPath) {
+		return false
+	}
+	return !matchTSConfigPatterns(tsConfig.Exclude, relPath)
+}
+
+func matchTSConfigPatterns(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		pattern = path.Clean(pattern)
+		// a pattern without wildcards or an extension names a directory
+		base := path.Base(pattern)
+		if !strings.ContainsAny(base, "*?") && path.Ext(base) == "" {
+			pattern = path.Join(pattern, "**/*")
+		}
+		if match, err := doublestar.Match(pattern, relPath); err == nil && match {
+			return true
+		}
+	}
+	return false
+}
+
+// referenceLabels returns the labels of the ts_project rules generated for
+// the projects referenced by the tsconfig file in the package rel
+func (tsConfig *tsConfigJSON) referenceLabels(rel string) []string {
+	labels := []string{}
+	for _, reference := range tsConfig.References {
+		dir := path.Join(rel, reference.Path)
+		if strings.HasSuffix(dir, ".json") {
+			dir = path.Dir(dir)
+		}
+		labels = append(labels, label.New("", dir, PkgName(dir)).Rel("", rel).String())
+	}
+	return labels
+}
+
+func (lang *JS) genTSConfigs(args language.GenerateArgs, jsConfig *JsConfig) ([]*rule.Rule, []interface{}) {
+	generatedRules := make([]*rule.Rule, 0)
+	generatedImports := make([]interface{}, 0)
+
+	if !jsConfig.TSConfigDiscovery {
+		return generatedRules, generatedImports
+	}
+
+	for _, baseName := range args.RegularFiles {
+		if !isTSConfigFile(baseName) {
+			continue
+		}
+
+		tsConfig, err := readTSConfig(path.Join(args.Dir, baseName))
+		if err != nil {
+			log.Fatalf(Err("failed to parse %s: %v", path.Join(args.Rel, baseName), err))
+		}
+
+		// each "extends" is resolved like an import
+		tsConfigImports := imports{
+			set: make(map[string]bool),
+		}
+		for _, extends := range tsConfig.Extends {
+			if strings.HasPrefix(extends, ".") && !strings.HasSuffix(extends, ".json") {
+				extends += ".json"
+			}
+			tsConfigImports.set[extends] = true
+		}
+
+		r := rule.NewRule(getKind(args.Config, "ts_config"), tsConfigRuleName(baseName))
+		r.SetAttr("src", baseName)
+		if len(jsConfig.Visibility.Labels) > 0 {
+			r.SetAttr("visibility", jsConfig.Visibility.Labels)
+		}
+
+		generatedRules = append(generatedRules, r)
+		generatedImports = append(generatedImports, &tsConfigImports)
+	}
+
+	return generatedRules, generatedImports
+}
+
+func (lang *JS) setTSConfigAttrs(args language.GenerateArgs, jsConfig *JsConfig, generatedRules []*rule.Rule) {
+	if jsConfig.TSConfig == label.NoLabel {
+		return
+	}
+	tsConfig := jsConfig.TSConfig.Rel("", args.Rel).String()
+	for _, r := range generatedRules {
+		if r.Kind() == getKind(args.Config, "ts_project") {
+			r.SetAttr("tsconfig", tsConfig)
+		}
+	}
+}
+
+func (lang *JS) setTSConfigProjectAttrs(args language.GenerateArgs, jsConfig *JsConfig, generatedRules []*rule.Rule) {
+	if jsConfig.TSConfigProject == nil || jsConfig.CollectAllRoot != args.Rel {
+		return
+	}
+	for _, r := range generatedRules {
+		if r.Kind() == getKind(args.Config, "ts_project") {
+			r.SetAttr("composite", true)
+			r.SetPrivateAttr(explicitDepsKey, jsConfig.TSConfigProject.referenceLabels(args.Rel))
+		}
+	}
+}
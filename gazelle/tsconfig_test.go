@@ -0,0 +1,124 @@
+// Copyright 2019 The Bazel Authors. All rights reserved.
+// Modifications copyright (C) 2021 BenchSci Analytics Inc.
+// Modifications copyright (C) 2018 Ecosia GmbH
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package js
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	for _, tc := range []struct {
+		desc, jsonc string
+		want        map[string]interface{}
+	}{
+		{
+			desc:  "plain",
+			jsonc: `{"extends": "./base.json"}`,
+			want:  map[string]interface{}{"extends": "./base.json"},
+		},
+		{
+			desc: "comments",
+			jsonc: `{
+	// line comment
+	"extends": "./base.json", /* block
+	comment */
+	"files": ["a.ts"]
+}`,
+			want: map[string]interface{}{"extends": "./base.json", "files": []interface{}{"a.ts"}},
+		},
+		{
+			desc:  "comment markers in strings",
+			jsonc: `{"include": ["src/**/*", "http://example.com"]}`,
+			want:  map[string]interface{}{"include": []interface{}{"src/**/*", "http://example.com"}},
+		},
+		{
+			desc: "trailing commas",
+			jsonc: `{
+	"files": ["a.ts", "b.ts",],
+	"compilerOptions": {"strict": true,},
+}`,
+			want: map[string]interface{}{
+				"files":           []interface{}{"a.ts", "b.ts"},
+				"compilerOptions": map[string]interface{}{"strict": true},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := map[string]interface{}{}
+			if err := json.Unmarshal(stripJSONC([]byte(tc.jsonc)), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Inequality.\ngot  %#v;\nwant %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTSConfigIncludes(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		tsConfig tsConfigJSON
+		relPath  string
+		want     bool
+	}{
+		{
+			desc:     "default include",
+			tsConfig: tsConfigJSON{},
+			relPath:  "src/a.ts",
+			want:     true,
+		},
+		{
+			desc:     "include directory",
+			tsConfig: tsConfigJSON{Include: []string{"./src"}},
+			relPath:  "src/nested/a.ts",
+			want:     true,
+		},
+		{
+			desc:     "outside include",
+			tsConfig: tsConfigJSON{Include: []string{"src"}},
+			relPath:  "scripts/a.ts",
+			want:     false,
+		},
+		{
+			desc:     "excluded",
+			tsConfig: tsConfigJSON{Include: []string{"src/**/*"}, Exclude: []string{"**/*.spec.ts"}},
+			relPath:  "src/a.spec.ts",
+			want:     false,
+		},
+		{
+			desc:     "files only",
+			tsConfig: tsConfigJSON{Files: []string{"./main.ts"}},
+			relPath:  "other.ts",
+			want:     false,
+		},
+		{
+			desc:     "listed file",
+			tsConfig: tsConfigJSON{Files: []string{"./main.ts"}},
+			relPath:  "main.ts",
+			want:     true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := tc.tsConfig.includes(tc.relPath); got != tc.want {
+				t.Errorf("includes(%q) = %v, want %v", tc.relPath, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+// Runs the bin of an npm package, found from the node_modules linked above
+// this file like any other import, without going through its exports.
+// __PACKAGE__ and __BIN__ are replaced by the macros of test_runners.bzl
+import { existsSync, readFileSync } from "node:fs";
+import { createRequire } from "node:module";
+import { join } from "node:path";
+import { pathToFileURL } from "node:url";
+
+const packageName = "__PACKAGE__";
+const binName = "__BIN__";
+
+const require = createRequire(import.meta.url);
+const packageDir = require.resolve
+  .paths(packageName)
+  .map((dir) => join(dir, packageName))
+  .find((dir) => existsSync(join(dir, "package.json")));
+if (!packageDir) {
+  throw new Error(`cannot find the ${packageName} package, add it to the test deps`);
+}
+const { bin } = JSON.parse(readFileSync(join(packageDir, "package.json"), "utf8"));
+await import(pathToFileURL(join(packageDir, typeof bin === "string" ? bin : bin[binName])).href);
@@ -0,0 +1,30 @@
+"""nextjs
+
+These are simple macros for the "next_build" and "next_dev" rules generated at the
+root of a Next.js app, they run `next build` with "js_run_binary" and `next dev` with
+"js_run_devserver" over the collected routes, public assets and config files
+This is kept seperate so that users can override them with gazelle's map_kind directive,
+eg. with the "next" macro of the Next.js example
+"""
+load("@aspect_rules_js//js:defs.bzl", "js_run_binary", "js_run_devserver")
+
+def next_build(name, srcs = [], deps = [], data = [], next_js_binary = "//:next_js_binary", **kwargs):
+    js_run_binary(
+        name = name,
+        tool = next_js_binary,
+        args = ["build"],
+        srcs = srcs + deps + data,
+        out_dirs = [".next"],
+        chdir = native.package_name(),
+        **kwargs
+    )
+
+def next_dev(name, srcs = [], deps = [], data = [], next_js_binary = "//:next_js_binary", **kwargs):
+    js_run_devserver(
+        name = name,
+        tool = next_js_binary,
+        args = ["dev"],
+        data = srcs + deps + data,
+        chdir = native.package_name(),
+        **kwargs
+    )
@@ -0,0 +1,33 @@
+"""sfc
+
+These are simple macros for the "vue_component", "svelte_component", "astro_component"
+and "mdx_library" rules generated for single-file components and MDX documents, they
+echo "js_library" with the files as srcs
+This is kept seperate so that users can override them with gazelle's map_kind directive,
+eg. with a macro compiling the components, "lang" is "ts" when a script block is TypeScript
+"""
+load("@aspect_rules_js//js:defs.bzl", "js_library")
+
+def vue_component(name, lang = "js", **kwargs):
+    js_library(
+        name = name,
+        **kwargs
+    )
+
+def svelte_component(name, lang = "js", **kwargs):
+    js_library(
+        name = name,
+        **kwargs
+    )
+
+def astro_component(name, **kwargs):
+    js_library(
+        name = name,
+        **kwargs
+    )
+
+def mdx_library(name, **kwargs):
+    js_library(
+        name = name,
+        **kwargs
+    )
@@ -0,0 +1,15 @@
+"""storybook
+
+This is a simple macro for the "storybook" rule aggregating the stories libraries
+of every package under the JS root, it echo's "js_library" with the stories as deps
+This is kept seperate so that users can override it with gazelle's map_kind directive,
+eg. with a macro building or serving storybook
+"""
+load("@aspect_rules_js//js:defs.bzl", "js_library")
+
+def storybook(name, stories = [], **kwargs):
+    js_library(
+        name = name,
+        deps = stories,
+        **kwargs
+    )
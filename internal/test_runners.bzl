@@ -0,0 +1,87 @@
+"""test runners
+
+These are simple macros for the test rules generated for each unit and end-to-end
+test runner, they run the test sources with "js_test".
+They are kept seperate so that users can override them with gazelle's map_kind directive
+"""
+load("@aspect_rules_js//js:defs.bzl", _js_test = "js_test")
+
+_TS_EXTENSIONS = [".ts", ".tsx", ".mts", ".cts"]
+
+def _bin_entry_point(name, package, bin):
+    """Writes an entry point running a bin of an npm package, which resolves
+    from the linked node_modules like any other source"""
+    native.genrule(
+        name = name + "_entry_point",
+        srcs = [Label("//internal:bin_entry_point.mjs")],
+        outs = [name + "_entry_point.mjs"],
+        cmd = "sed -e 's|__PACKAGE__|%s|' -e 's|__BIN__|%s|' $< > $@" % (package, bin),
+        testonly = True,
+    )
+    return name + "_entry_point.mjs"
+
+def node_test(name, srcs, deps = [], data = [], **kwargs):
+    """Runs test files with the node test runner, node --test
+
+    TypeScript files are run through the tsx loader, which must be a dependency
+    """
+    node_options = kwargs.pop("node_options", [])
+    args = ["$(rootpath %s)" % src for src in srcs[1:]] + kwargs.pop("args", [])
+    if [src for src in srcs for ext in _TS_EXTENSIONS if src.endswith(ext)]:
+        node_options = ["--import", "tsx"] + node_options
+    _js_test(
+        name = name,
+        entry_point = srcs[0],
+        node_options = ["--test"] + node_options,
+        args = args,
+        data = srcs + deps + data,
+        **kwargs
+    )
+
+def vitest_test(name, srcs, entry_point = None, config = None, deps = [], data = [], **kwargs):
+    """Runs test files with the vitest CLI, from the vitest package unless given as entry_point"""
+    entry_point = entry_point or _bin_entry_point(name, "vitest", "vitest")
+    config_args = ["--config", "$(rootpath %s)" % config] if config else []
+    _js_test(
+        name = name,
+        entry_point = entry_point,
+        args = ["run"] + config_args + ["$(rootpath %s)" % src for src in srcs],
+        data = srcs + deps + data + ([config] if config else []),
+        **kwargs
+    )
+
+def mocha_test(name, srcs, entry_point = None, config = None, deps = [], data = [], **kwargs):
+    """Runs test files with the mocha CLI, from the mocha package unless given as entry_point"""
+    entry_point = entry_point or _bin_entry_point(name, "mocha", "mocha")
+    config_args = ["--config", "$(rootpath %s)" % config] if config else []
+    _js_test(
+        name = name,
+        entry_point = entry_point,
+        args = config_args + ["$(rootpath %s)" % src for src in srcs],
+        data = srcs + deps + data + ([config] if config else []),
+        **kwargs
+    )
+
+def playwright_test(name, srcs, entry_point = None, config = None, deps = [], data = [], **kwargs):
+    """Runs end-to-end test files with the playwright CLI, from the @playwright/test package unless given as entry_point"""
+    entry_point = entry_point or _bin_entry_point(name, "@playwright/test", "playwright")
+    config_args = ["--config", "$(rootpath %s)" % config] if config else []
+    _js_test(
+        name = name,
+        entry_point = entry_point,
+        args = ["test"] + config_args + ["$(rootpath %s)" % src for src in srcs],
+        data = srcs + deps + data + ([config] if config else []),
+        **kwargs
+    )
+
+def cypress_test(name, srcs, entry_point = None, config = None, deps = [], data = [], **kwargs):
+    """Runs end-to-end test files with the cypress CLI, from the cypress package unless given as entry_point"""
+    entry_point = entry_point or _bin_entry_point(name, "cypress", "cypress")
+    config_args = ["--config-file", "$(rootpath %s)" % config] if config else []
+    _js_test(
+        name = name,
+        entry_point = entry_point,
+        args = ["run"] + config_args + ["--spec", ",".join(["$(rootpath %s)" % src for src in srcs])],
+        data = srcs + deps + data + ([config] if config else []),
+        **kwargs
+    )
@@ -0,0 +1 @@
+export const c1 = 1;
@@ -0,0 +1 @@
+export const c2 = 1;
@@ -0,0 +1 @@
+export const c3 = 1;
@@ -0,0 +1,3 @@
+import { y } from '../y/y';
+
+export const x = 1;
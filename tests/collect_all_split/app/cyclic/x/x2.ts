@@ -0,0 +1 @@
+export const x2 = 1;
@@ -0,0 +1,3 @@
+import { x } from '../x/x';
+
+export const y = 1;
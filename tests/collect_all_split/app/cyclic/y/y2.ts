@@ -0,0 +1 @@
+export const y2 = 1;
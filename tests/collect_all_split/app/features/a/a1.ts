@@ -0,0 +1,3 @@
+import { c1 } from '../../core/c1';
+
+export const a1 = c1;
@@ -0,0 +1 @@
+export const a2 = 1;
@@ -0,0 +1 @@
+export const main = 1;
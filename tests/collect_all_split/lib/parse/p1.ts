@@ -0,0 +1 @@
+export const p1 = 1;
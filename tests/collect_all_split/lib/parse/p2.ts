@@ -0,0 +1 @@
+export const p2 = 1;
@@ -0,0 +1 @@
+export const q1 = 1;
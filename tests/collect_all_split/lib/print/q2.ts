@@ -0,0 +1 @@
+export const q2 = 1;
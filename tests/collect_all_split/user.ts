@@ -0,0 +1,3 @@
+import { a1 } from './app/features/a/a1';
+
+export const user = a1;
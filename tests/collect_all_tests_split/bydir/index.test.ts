@@ -0,0 +1,5 @@
+import { add } from '.';
+
+it('adds', () => {
+  expect(add(1, 2)).toBe(3);
+});
@@ -0,0 +1,5 @@
+import { add } from './add';
+
+it('adds', () => {
+  expect(add(2, 2)).toBe(4);
+});
@@ -0,0 +1,3 @@
+it('is deep', () => {
+  expect(true).toBe(true);
+});
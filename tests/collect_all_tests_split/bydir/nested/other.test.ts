@@ -0,0 +1,3 @@
+it('works without imports', () => {
+  expect(true).toBe(true);
+});
@@ -0,0 +1 @@
+export { add } from './nested/add';
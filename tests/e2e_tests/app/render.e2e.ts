@@ -0,0 +1,6 @@
+import { test, expect } from "@playwright/test"
+import { render } from "./render"
+
+test("renders", async () => {
+    expect(render()).toContain("Hello")
+})
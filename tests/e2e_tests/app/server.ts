@@ -0,0 +1,4 @@
+#!/usr/bin/env node
+import { render } from "./render"
+
+render()
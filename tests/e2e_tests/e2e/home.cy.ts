@@ -0,0 +1,7 @@
+import { homePage } from "./pages"
+
+describe("home", () => {
+    it("loads", () => {
+        cy.visit(homePage)
+    })
+})
@@ -0,0 +1,4 @@
+import { n } from '../legacy/nested';
+import { u } from '../utils/u';
+
+export const main = n + u;
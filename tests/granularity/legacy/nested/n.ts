@@ -0,0 +1,3 @@
+import { l } from '..';
+
+export const n = l;
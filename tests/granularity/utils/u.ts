@@ -0,0 +1,3 @@
+import { a } from '../components/a';
+
+export const u = a;
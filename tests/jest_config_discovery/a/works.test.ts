@@ -0,0 +1 @@
+it("works", () => {})
@@ -0,0 +1,7 @@
+import type { Config } from "jest"
+
+const config: Config = {
+    testEnvironment: "node",
+}
+
+export default config
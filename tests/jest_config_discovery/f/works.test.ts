@@ -0,0 +1,3 @@
+test("works", () => {
+  expect(1).toBe(1);
+});
@@ -0,0 +1,6 @@
+import { classNames } from "@/util"
+import "./button.css"
+
+it("joins class names", () => {
+    expect(classNames("a", "b")).toBe("a b")
+})
@@ -0,0 +1 @@
+process.env.TZ = "UTC"
@@ -0,0 +1,3 @@
+export function classNames(...names: string[]) {
+    return names.join(" ")
+}
@@ -0,0 +1,3 @@
+test('calls the api', () => {
+  expect(true).toBe(true);
+});
@@ -0,0 +1,5 @@
+import { sum } from './sum';
+
+test('adds', () => {
+  expect(sum(1, 2)).toBe(3);
+});
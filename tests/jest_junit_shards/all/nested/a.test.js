@@ -0,0 +1,3 @@
+test('nested a works', () => {
+  expect(true).toBe(true);
+});
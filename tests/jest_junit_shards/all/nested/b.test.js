@@ -0,0 +1,3 @@
+test('nested b works', () => {
+  expect(true).toBe(true);
+});
@@ -0,0 +1,3 @@
+test('nested c works', () => {
+  expect(true).toBe(true);
+});
@@ -0,0 +1,3 @@
+test('fast', () => {
+  expect(1 + 1).toBe(2);
+});
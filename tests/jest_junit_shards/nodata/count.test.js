@@ -0,0 +1,3 @@
+it('one', () => {});
+it('two', () => {});
+it('three', () => {});
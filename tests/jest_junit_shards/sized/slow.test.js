@@ -0,0 +1,4 @@
+it('one', () => {});
+it('two', () => {});
+it('three', () => {});
+it('four', () => {});
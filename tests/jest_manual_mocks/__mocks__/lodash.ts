@@ -0,0 +1 @@
+export const chunk = jest.fn()
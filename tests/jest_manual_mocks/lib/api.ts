@@ -0,0 +1,3 @@
+export async function fetchUser(id: string) {
+    return { id }
+}
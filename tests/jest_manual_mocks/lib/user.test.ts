@@ -0,0 +1,9 @@
+import { chunk } from "lodash"
+import { userName } from "./user"
+
+jest.mock("./api")
+
+it("reads the name", async () => {
+    await userName("1")
+    chunk([])
+})
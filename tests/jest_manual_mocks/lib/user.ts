@@ -0,0 +1,5 @@
+import { fetchUser } from "./api"
+
+export async function userName(id: string) {
+    return (await fetchUser(id)).name
+}
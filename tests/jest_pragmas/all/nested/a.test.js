@@ -0,0 +1,5 @@
+/**
+ * @bazel-size medium
+ * @bazel-tags integration
+ */
+test('a', () => {});
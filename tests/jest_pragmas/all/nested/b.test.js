@@ -0,0 +1,6 @@
+/**
+ * @bazel-size large
+ * @bazel-tags manual
+ * @jest-environment ./environment.js
+ */
+test('b', () => {});
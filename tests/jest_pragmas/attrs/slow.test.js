@@ -0,0 +1,8 @@
+/**
+ * @bazel-size large
+ * @bazel-tags manual,integration
+ * @flaky
+ */
+test('is slow', () => {
+  expect(true).toBe(true);
+});
@@ -0,0 +1,4 @@
+/** @jest-environment ./environment.js */
+test('has a custom environment', () => {
+  expect(globalThis.custom).toBe(true);
+});
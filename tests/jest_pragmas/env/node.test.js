@@ -0,0 +1,2 @@
+/** @jest-environment node */
+it("runs in node", () => {});
@@ -0,0 +1,3 @@
+export function label(text: string) {
+    return `[${text}]`
+}
@@ -0,0 +1,5 @@
+import { label } from "./label"
+
+it("renders", () => {
+    expect(label("ok")).toMatchSnapshot()
+})
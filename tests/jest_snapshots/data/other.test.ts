@@ -0,0 +1 @@
+it("has no snapshot", () => {})
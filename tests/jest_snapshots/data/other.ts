@@ -0,0 +1 @@
+export const unused = true
@@ -0,0 +1,3 @@
+import { greet } from "./lib"
+
+console.log(greet("extra"))
@@ -0,0 +1,4 @@
+#!/usr/bin/env node
+import { greet } from "./lib"
+
+console.log(greet("script"))
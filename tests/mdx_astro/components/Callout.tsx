@@ -0,0 +1,3 @@
+export default function Callout({ children }: { children: React.ReactNode }) {
+  return <aside>{children}</aside>;
+}
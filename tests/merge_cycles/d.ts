@@ -0,0 +1 @@
+export const d = 1;
@@ -0,0 +1,5 @@
+import Header from "../../components/Header";
+
+export default function About() {
+  return <Header title="About" />;
+}
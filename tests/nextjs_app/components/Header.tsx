@@ -0,0 +1,5 @@
+import React from "react";
+
+export default function Header({ title }: { title: string }) {
+  return <h1>{title}</h1>;
+}
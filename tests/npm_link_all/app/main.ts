@@ -0,0 +1,3 @@
+import { hello } from "@acme/lib"
+
+console.log(hello)
@@ -0,0 +1 @@
+export const hello = "world"
@@ -0,0 +1,4 @@
+import { greet } from "./greet"
+import { shout } from "./src/shout"
+
+export const hello = shout(greet("world"))
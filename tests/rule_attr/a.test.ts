@@ -0,0 +1,5 @@
+import { a } from './a';
+
+it('works', () => {
+    expect(a).toBe(1);
+});
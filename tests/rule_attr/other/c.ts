@@ -0,0 +1 @@
+export const c = 3;
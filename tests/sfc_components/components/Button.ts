@@ -0,0 +1 @@
+export const buttonSizes = ["small", "large"];
@@ -0,0 +1,4 @@
+import { createApp } from "vue";
+import Card from "./components/Card.vue";
+
+createApp(Card).mount("#app");
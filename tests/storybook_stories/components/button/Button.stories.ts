@@ -0,0 +1,7 @@
+import type { Meta } from '@storybook/react';
+import { Button } from './Button';
+
+const meta: Meta = { title: 'Button', component: Button };
+export default meta;
+
+export const Primary = { args: { label: 'Button' } };
@@ -0,0 +1 @@
+export const Button = (label: string) => `<button>${label}</button>`;
@@ -0,0 +1 @@
+export const Card = (title) => `<div>${title}</div>`;
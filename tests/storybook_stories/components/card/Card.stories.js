@@ -0,0 +1,5 @@
+import { Card } from './Card';
+
+export default { title: 'Card', component: Card };
+
+export const Default = { args: { title: 'Card' } };
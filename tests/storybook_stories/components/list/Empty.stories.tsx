@@ -0,0 +1,4 @@
+import type { Meta } from '@storybook/react';
+
+const meta: Meta = { title: 'List/Empty' };
+export default meta;
@@ -0,0 +1,3 @@
+import { stories } from './stories';
+
+export default { title: stories[0] };
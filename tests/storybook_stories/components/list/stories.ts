@@ -0,0 +1 @@
+export const stories = ['List'];
@@ -0,0 +1,5 @@
+const fs = require('fs');
+
+it('reads', () => {
+  expect(fs.readFileSync(`${__dirname}/data/table.csv`, 'utf8')).toBe('a,b\n');
+});
@@ -0,0 +1 @@
+module.exports = (name) => require('fs').readFileSync(`${__dirname}/../data/${name}`, 'utf8');
@@ -0,0 +1,6 @@
+import fs from 'fs';
+import path from 'path';
+
+it('formats', () => {
+  expect(fs.readFileSync(path.join(__dirname, '../__fixtures__/nested/deep.txt'), 'utf8')).toBe('deep\n');
+});
@@ -0,0 +1,8 @@
+import fs from 'fs';
+import path from 'path';
+import { parse } from './parser';
+
+it('parses', () => {
+  const text = fs.readFileSync(path.join(__dirname, '__fixtures__/input.json'), 'utf8');
+  expect(parse(text)).toEqual({ ok: true });
+});
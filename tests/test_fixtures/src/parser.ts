@@ -0,0 +1 @@
+export const parse = (text: string) => JSON.parse(text);
@@ -0,0 +1,3 @@
+test("logs in", () => {
+  expect(true).toBe(true);
+});
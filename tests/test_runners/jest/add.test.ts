@@ -0,0 +1,5 @@
+import { add } from "./add"
+
+it("adds", () => {
+    add(1, 2)
+})
@@ -0,0 +1 @@
+export const fake = () => 'fake';
@@ -0,0 +1,3 @@
+import { render } from './testing/render';
+
+export const fakeWidget = () => render();
@@ -0,0 +1,3 @@
+import { widget } from './widget';
+
+export const renderWidget = () => widget('test');
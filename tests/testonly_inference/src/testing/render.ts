@@ -0,0 +1,3 @@
+import { render as renderComponent } from '@testing-library/react';
+
+export const render = () => renderComponent(null);
@@ -0,0 +1,9 @@
+import { renderWidget } from './test-utils';
+import { render } from './testing/render';
+import { fakeWidget } from './fake-widget';
+
+it('renders', () => {
+  expect(renderWidget()).toBe('<test/>');
+  expect(render()).toBeDefined();
+  expect(fakeWidget()).toBeDefined();
+});
@@ -0,0 +1 @@
+export const widget = (name: string) => `<${name}/>`;
@@ -0,0 +1,2 @@
+export * from '../lib/c';
+export const b = 1;
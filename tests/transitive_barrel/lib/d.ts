@@ -0,0 +1,3 @@
+import { a } from '.';
+
+export const d = a;
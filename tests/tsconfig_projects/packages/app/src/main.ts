@@ -0,0 +1,3 @@
+import { u } from '../../core/src';
+
+export const main = u;
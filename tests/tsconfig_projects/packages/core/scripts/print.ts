@@ -0,0 +1,3 @@
+import { u } from '../src';
+
+console.log(u);
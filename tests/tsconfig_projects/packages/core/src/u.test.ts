@@ -0,0 +1,5 @@
+import { u } from './util/u';
+
+it('works', () => {
+    expect(u).toBe(1);
+});